@@ -1,39 +1,177 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/onboarding"
+	"github.com/api_base/internal/domain/token"
+	"github.com/api_base/internal/repository"
+	"github.com/api_base/internal/repository/outbox"
 	"github.com/api_base/tool/database"
+	"github.com/api_base/tool/jwt"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/ratelimit"
 	"github.com/api_base/tool/restclient"
+	"github.com/api_base/tool/secrets"
+	"github.com/api_base/tool/server"
+	"github.com/api_base/tool/tracing"
+	"github.com/api_base/tool/validation"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
 )
 
 const (
 	filePathFormat = "%s/config/%s.yml"
+	defaultsFile   = "default"
+	defaultEnv     = "local"
+	envVariable    = "APP_ENV"
 )
 
 type Config struct {
-	Database   database.Config   `yaml:"database"`
-	RestClient restclient.Config `yaml:"rest_client"`
+	Auth           auth.Config           `yaml:"auth"`
+	Authorization  auth.PolicyConfig     `yaml:"authorization"`
+	Database       database.Config       `yaml:"database"`
+	JWT            jwt.Config            `yaml:"jwt"`
+	Log            logger.Config         `yaml:"log"`
+	Onboarding     onboarding.Config     `yaml:"onboarding"`
+	Outbox         outbox.Config         `yaml:"outbox"`
+	RateLimit      ratelimit.Config      `yaml:"rate_limit"`
+	Reload         ReloadConfig          `yaml:"reload"`
+	Repository     repository.Config     `yaml:"repository"`
+	RestClient     restclient.Config     `yaml:"rest_client"`
+	Response       response.Config       `yaml:"response"`
+	Secrets        secrets.Config        `yaml:"secrets"`
+	Server         server.Config         `yaml:"server"`
+	TokenLifecycle token.LifecycleConfig `yaml:"token_lifecycle"`
+	Tracing        tracing.Config        `yaml:"tracing"`
 }
 
-func NewConfig() Config {
-	basePath, err := os.Getwd()
+// Options describes where the configuration is read from.
+type Options struct {
+	// BasePath directory containing the config folder
+	BasePath string
+	// Env selects config/{env}.yml, defaults to local
+	Env string
+	// File replaces the environment file when set
+	File string
+	// Environ variables used as overrides, in os.Environ format
+	Environ []string
+}
+
+// NewConfig loads the configuration of the environment named by APP_ENV from the
+// working directory. A non empty file replaces the environment file.
+func NewConfig(file string) (Config, error) {
+	opts, err := NewOptions(file)
 	if err != nil {
-		log.Fatalf("get wd error: %v ", err)
+		return Config{}, err
 	}
-	filePath := fmt.Sprintf(filePathFormat, basePath, "local")
+	return Load(opts)
+}
 
-	configFile, err := ioutil.ReadFile(filePath)
+// NewOptions returns the options NewConfig loads the configuration with.
+func NewOptions(file string) (Options, error) {
+	basePath, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("read yaml file error: %v ", err)
+		return Options{}, fmt.Errorf("get wd error: %w", err)
 	}
+	return Options{
+		BasePath: basePath,
+		Env:      os.Getenv(envVariable),
+		File:     file,
+		Environ:  os.Environ(),
+	}, nil
+}
 
+// files returns the defaults file and the environment file, in loading order.
+func (opts Options) files() ([]string, error) {
+	file := opts.File
+	if file == "" {
+		env := opts.Env
+		if env == "" {
+			env = defaultEnv
+		}
+		if filepath.Base(env) != env {
+			return nil, fmt.Errorf("invalid environment %q", env)
+		}
+		file = fmt.Sprintf(filePathFormat, opts.BasePath, env)
+	}
+	return []string{fmt.Sprintf(filePathFormat, opts.BasePath, defaultsFile), file}, nil
+}
+
+// Load reads config/default.yml when present, overlays the environment file on top
+// of it, applies the overrides found in the environment variables and finally resolves
+// the secret references.
+func Load(opts Options) (Config, error) {
+	files, err := opts.files()
+	if err != nil {
+		return Config{}, err
+	}
 	configuration := Config{}
-	if err := yaml.Unmarshal(configFile, &configuration); err != nil {
-		log.Fatalf("error: %v", err)
+	if err := loadFile(&configuration, files[0], true); err != nil {
+		return Config{}, err
+	}
+	if err := loadFile(&configuration, files[1], false); err != nil {
+		return Config{}, err
+	}
+	if err := applyEnv(&configuration, opts.Environ); err != nil {
+		return Config{}, err
+	}
+	if err := resolveSecrets(&configuration, opts.Environ); err != nil {
+		return Config{}, err
+	}
+	return configuration, nil
+}
+
+func loadFile(configuration *Config, path string, optional bool) error {
+	configFile, err := ioutil.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read yaml file error: %w", err)
+	}
+	if err := yaml.Unmarshal(configFile, configuration); err != nil {
+		return fmt.Errorf("parse yaml file %s error: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports the problems of every section at once, each prefixed by its yaml path.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if c.Auth.Enabled {
+		problems.Merge("auth", c.Auth.Validate())
+	}
+	problems.Merge("authorization", c.Authorization.Validate())
+	if !c.Repository.Memory() {
+		problems.Merge("database", c.Database.Validate())
+	}
+	problems.Merge("jwt", c.JWT.Validate())
+	problems.Merge("log", c.Log.Validate())
+	problems.Merge("onboarding", c.Onboarding.Validate())
+	problems.Merge("outbox", c.Outbox.Validate())
+	if c.Outbox.Enabled && c.Outbox.Webhook() {
+		if _, ok := c.RestClient.ExternalApiCalls[outbox.WebhookApi].Resources[outbox.PublishResource]; !ok {
+			problems.Addf("rest_client.external_calls."+outbox.WebhookApi+".resources."+outbox.PublishResource, "is required by the %s sink", outbox.SinkWebhook)
+		}
+	}
+	if c.RateLimit.Enabled {
+		problems.Merge("rate_limit", c.RateLimit.Validate())
+	}
+	problems.Merge("repository", c.Repository.Validate())
+	problems.Merge("rest_client", c.RestClient.Validate())
+	problems.Merge("response", c.Response.Validate())
+	problems.Merge("server", c.Server.Validate())
+	problems.Merge("token_lifecycle", c.TokenLifecycle.Validate())
+	for i, scope := range c.TokenLifecycle.Scopes {
+		if !auth.KnownPermission(auth.Permission(scope)) {
+			problems.Addf(fmt.Sprintf("token_lifecycle.scopes[%d]", i), "unknown permission %q", scope)
+		}
 	}
-	return configuration
+	problems.Merge("tracing", c.Tracing.Validate())
+	return problems.Err()
 }
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"github.com/api_base/internal/repository/outbox"
+	"github.com/api_base/tool/ratelimit"
+	"github.com/api_base/tool/validation"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, "config", name+".yml")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad_LayersDefaultsEnvFileAndVariables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeConfig(t, dir, "default", `
+database:
+  driver: mysql
+  host: localhost
+  max_open_connections: 10
+server:
+  address: ":3000"
+authorization:
+  roles:
+    admin: [users:read]
+`)
+	writeConfig(t, dir, "staging", `
+database:
+  host: staging-db
+authorization:
+  roles:
+    user: [users:read:self]
+`)
+
+	conf, err := Load(Options{
+		BasePath: dir,
+		Env:      "staging",
+		Environ: []string{
+			"DATABASE_NAME=api_base",
+			"DATABASE_MAX_OPEN_CONNECTIONS=25",
+			"DATABASE_CONNECTION_TIMEOUT=2s",
+			"AUTH_ENABLED=true",
+			"AUTHORIZATION_DEFAULT_ROLES=user, guest",
+			"SERVER_READ_TIMEOUT_SECONDS=7",
+			"UNRELATED=value",
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql", conf.Database.Driver)
+	assert.Equal(t, "staging-db", conf.Database.DbHost)
+	assert.Equal(t, "api_base", conf.Database.DbName)
+	assert.Equal(t, 25, conf.Database.MaxOpenConns)
+	assert.Equal(t, 2*time.Second, *conf.Database.ConnTimeout)
+	assert.True(t, conf.Auth.Enabled)
+	assert.Equal(t, []string{"user", "guest"}, conf.Authorization.DefaultRoles)
+	assert.Equal(t, time.Duration(7), conf.Server.ReadTimeoutSeconds)
+	assert.Equal(t, ":3000", conf.Server.Address)
+	assert.Len(t, conf.Authorization.Roles, 2)
+}
+
+func TestLoad_ExplicitFileAndDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeConfig(t, dir, "local", "database:\n  host: local-db\n")
+	custom := writeConfig(t, dir, "custom", "database:\n  host: custom-db\n")
+
+	conf, err := Load(Options{BasePath: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, "local-db", conf.Database.DbHost)
+
+	conf, err = Load(Options{BasePath: dir, Env: "local", File: custom})
+	assert.Nil(t, err)
+	assert.Equal(t, "custom-db", conf.Database.DbHost)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeConfig(t, dir, "local", "database:\n  host: local-db\n")
+	writeConfig(t, dir, "broken", "database: [\n")
+
+	_, err = Load(Options{BasePath: dir, Env: "missing"})
+	assert.NotNil(t, err)
+	_, err = Load(Options{BasePath: dir, Env: "broken"})
+	assert.NotNil(t, err)
+	_, err = Load(Options{BasePath: dir, Env: "../local"})
+	assert.NotNil(t, err)
+	_, err = Load(Options{BasePath: dir, Environ: []string{"DATABASE_MAX_OPEN_CONNECTIONS=many"}})
+	assert.EqualError(t, err, `invalid value for DATABASE_MAX_OPEN_CONNECTIONS: strconv.ParseInt: parsing "many": invalid syntax`)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	conf := Config{}
+	conf.Database.Driver = "postgres"
+	conf.Database.MaxOpenConns = -1
+	conf.RateLimit = ratelimit.Config{Enabled: true, Key: "session", Default: ratelimit.Limit{Rate: -1}}
+	conf.RestClient.TimeoutMillis = 1000
+	conf.Server.IdleTimeoutSeconds = -5
+
+	problems, ok := conf.Validate().(validation.Problems)
+
+	assert.True(t, ok)
+	assert.Equal(t, validation.Problems{
+		`database.driver: unknown driver "postgres", registered drivers: [mysql]`,
+		"database.host: is required",
+		"database.name: is required",
+		"database.user: is required",
+		"database.max_open_connections: must not be negative",
+		`rate_limit.key: unknown key "session", expected one of ip, api_key, user`,
+		"rate_limit.default.rate: must not be negative",
+		"server.idle_timeout_seconds: must not be negative",
+	}, problems)
+}
+
+func TestConfig_ValidateOutboxWebhook(t *testing.T) {
+	conf := Config{}
+	conf.Repository.Driver = "memory"
+	conf.RestClient.TimeoutMillis = 1000
+	conf.Outbox = outbox.Config{Enabled: true, IntervalSeconds: 1, BatchSize: 10, MaxAttempts: 3, Sinks: []outbox.SinkConfig{{Type: outbox.SinkWebhook}}}
+
+	problems, _ := conf.Validate().(validation.Problems)
+
+	assert.Equal(t, validation.Problems{
+		"rest_client.external_calls.event_webhook.resources.publish_event: is required by the webhook sink",
+	}, problems)
+}
+
+func TestConfig_ValidateRepositoryFiles(t *testing.T) {
+	basePath, err := os.Getwd()
+	assert.Nil(t, err)
+	conf, err := Load(Options{BasePath: filepath.Dir(basePath), Environ: []string{"DB_PASSWORD=password", "JWT_SECRET=secret"}})
+	assert.Nil(t, err)
+	assert.Nil(t, conf.Validate())
+}
+
+func TestLoad_ResolvesSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "db_password")
+	assert.Nil(t, ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600))
+	writeConfig(t, dir, "local", `
+database:
+  host: ${env:DB_HOST}
+  password: ${file:`+secretFile+`}
+rest_client:
+  external_calls:
+    token_api:
+      resources:
+        get_token:
+          auth:
+            user: api
+            password: ${env:TOKEN_API_PASSWORD}
+`)
+
+	conf, err := Load(Options{BasePath: dir, Environ: []string{"DB_HOST=db", "TOKEN_API_PASSWORD=from-env"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "db", conf.Database.DbHost)
+	assert.Equal(t, "from-file", conf.Database.DbPassword)
+	assert.Equal(t, "from-env", conf.RestClient.ExternalApiCalls["token_api"].Resources["get_token"].Auth.Password)
+
+	_, err = Load(Options{BasePath: dir, Environ: []string{"DB_HOST=db"}})
+	assert.EqualError(t, err, `rest_client.external_calls.token_api.resources.get_token.auth.password: resolve env secret "TOKEN_API_PASSWORD": secrets_not_found`)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides scalar fields with the environment variable named after their
+// yaml path, e.g. database.host is overridden by DATABASE_HOST. Durations keep the
+// unit implied by the field unless written as a duration ("5s") and lists are
+// comma separated.
+func applyEnv(configuration *Config, environ []string) error {
+	values := map[string]string{}
+	for _, entry := range environ {
+		if i := strings.Index(entry, "="); i > 0 {
+			values[entry[:i]] = entry[i+1:]
+		}
+	}
+	return overrideStruct(reflect.ValueOf(configuration).Elem(), "", values)
+}
+
+func overrideStruct(v reflect.Value, prefix string, values map[string]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := strings.ToUpper(tag)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := overrideStruct(fv, name, values); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Type() == durationType {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			v.SetInt(n)
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
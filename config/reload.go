@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/api_base/tool/logger"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// default values
+const (
+	defaultReloadInterval = 5 * time.Second
+)
+
+// ReloadConfig enables applying config changes without a restart, when the loaded
+// files change or a SIGHUP is received.
+type ReloadConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	IntervalSeconds time.Duration `yaml:"interval_seconds"`
+}
+
+// Interval returns how often the loaded files are checked for changes.
+func (c ReloadConfig) Interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return defaultReloadInterval
+	}
+	return c.IntervalSeconds * time.Second
+}
+
+// Store publishes the current config, readers always get a complete and validated one.
+type Store struct {
+	value atomic.Value
+}
+
+func NewStore(conf Config) *Store {
+	s := &Store{}
+	s.value.Store(conf)
+	return s
+}
+
+// Load returns the current config.
+func (s *Store) Load() Config {
+	return s.value.Load().(Config)
+}
+
+// Listener applies a config change to a running component.
+type Listener func(old, current Config) error
+
+// Watcher reloads the config of its Options and publishes it to a Store. Invalid
+// configs are rejected and the current one is kept.
+type Watcher struct {
+	mu        sync.Mutex
+	opts      Options
+	store     *Store
+	logger    *logger.Logger
+	listeners []Listener
+	stamps    map[string]time.Time
+}
+
+func NewWatcher(opts Options, store *Store, lg *logger.Logger) *Watcher {
+	w := &Watcher{opts: opts, store: store, logger: lg}
+	w.stamps = w.modTimes()
+	return w
+}
+
+// OnChange registers listener to be called after every published config.
+func (w *Watcher) OnChange(listener Listener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+// Reload loads and validates the config, publishes it and calls the listeners. The
+// listener errors are returned once all of them ran.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stamps = w.modTimes()
+	current, err := Load(w.opts)
+	if err != nil {
+		return err
+	}
+	if err := current.Validate(); err != nil {
+		return err
+	}
+	old := w.store.Load()
+	w.store.value.Store(current)
+	failed := []string{}
+	for _, listener := range w.listeners {
+		if err := listener(old, current); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("apply config fail: %v", failed)
+	}
+	return nil
+}
+
+// Watch reloads the config when one of the loaded files changes or a value is received
+// from signals, until ctx is done. Failed reloads are logged.
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration, signals <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+		}
+		if err := w.Reload(); err != nil {
+			w.logger.Error(ctx, "reload config fail", logger.Err(err))
+			continue
+		}
+		w.logger.Info(ctx, "config reloaded")
+	}
+}
+
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stamps := w.modTimes()
+	if len(stamps) != len(w.stamps) {
+		return true
+	}
+	for file, modTime := range stamps {
+		if !w.stamps[file].Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) modTimes() map[string]time.Time {
+	stamps := map[string]time.Time{}
+	files, err := w.opts.files()
+	if err != nil {
+		return stamps
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = info.ModTime()
+		}
+	}
+	return stamps
+}
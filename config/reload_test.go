@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/api_base/tool/logger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const reloadBase = `
+database:
+  driver: mysql
+  host: localhost
+  name: api_base
+  user: api
+rest_client:
+  timeout: %d
+`
+
+func TestWatcher_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, "local", fmt.Sprintf(reloadBase, 1000))
+	opts := Options{BasePath: dir}
+	conf, err := Load(opts)
+	assert.Nil(t, err)
+	store := NewStore(conf)
+	watcher := NewWatcher(opts, store, logger.Discard())
+	changes := []time.Duration{}
+	watcher.OnChange(func(old, current Config) error {
+		changes = append(changes, old.RestClient.TimeoutMillis, current.RestClient.TimeoutMillis)
+		return nil
+	})
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(reloadBase, 2000)), 0644))
+	assert.Nil(t, watcher.Reload())
+	assert.Equal(t, time.Duration(2000), store.Load().RestClient.TimeoutMillis)
+	assert.Equal(t, []time.Duration{1000, 2000}, changes)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(reloadBase, -1)), 0644))
+	assert.NotNil(t, watcher.Reload())
+	assert.Equal(t, time.Duration(2000), store.Load().RestClient.TimeoutMillis, "invalid configs are not published")
+	assert.Len(t, changes, 2)
+
+	watcher.OnChange(func(old, current Config) error { return errors.New("reconnect fail") })
+	assert.Nil(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(reloadBase, 3000)), 0644))
+	assert.EqualError(t, watcher.Reload(), "apply config fail: [reconnect fail]")
+	assert.Equal(t, time.Duration(3000), store.Load().RestClient.TimeoutMillis)
+}
+
+func TestWatcher_WatchReloadsChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, "local", fmt.Sprintf(reloadBase, 1000))
+	opts := Options{BasePath: dir}
+	conf, err := Load(opts)
+	assert.Nil(t, err)
+	store := NewStore(conf)
+	watcher := NewWatcher(opts, store, logger.Discard())
+	reloaded := make(chan Config, 1)
+	watcher.OnChange(func(_, current Config) error {
+		reloaded <- current
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Watch(ctx, 10*time.Millisecond, nil)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(fmt.Sprintf(reloadBase, 2000)), 0644))
+	later := time.Now().Add(time.Second)
+	assert.Nil(t, os.Chtimes(path, later, later))
+
+	select {
+	case current := <-reloaded:
+		assert.Equal(t, time.Duration(2000), current.RestClient.TimeoutMillis)
+	case <-time.After(2 * time.Second):
+		t.Fatal("config not reloaded")
+	}
+}
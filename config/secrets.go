@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"github.com/api_base/tool/secrets"
+	"reflect"
+)
+
+// resolveSecrets replaces every string written as ${provider:ref}, wherever it is in the
+// config, with the secret it references.
+func resolveSecrets(configuration *Config, environ []string) error {
+	resolver := secrets.NewResolver(configuration.Secrets, environ)
+	return resolveValue(reflect.ValueOf(configuration).Elem(), "", resolver)
+}
+
+func resolveValue(v reflect.Value, path string, resolver *secrets.Resolver) error {
+	switch v.Kind() {
+	case reflect.String:
+		secret, err := resolver.Resolve(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(secret)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return resolveValue(v.Elem(), path, resolver)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if err := resolveValue(v.Field(i), joinPath(path, t.Field(i).Tag.Get("yaml")), resolver); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), resolver); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map values aren't addressable, they are resolved on a copy and stored back
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			if err := resolveValue(value, joinPath(path, fmt.Sprint(key.Interface())), resolver); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	for i, c := range name {
+		if c == ',' {
+			name = name[:i]
+			break
+		}
+	}
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
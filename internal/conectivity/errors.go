@@ -0,0 +1,62 @@
+package conectivity
+
+import (
+	"errors"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/tool/logger"
+	"net/http"
+)
+
+var statusByKind = map[fault.Kind]int{
+	fault.Internal:        http.StatusInternalServerError,
+	fault.NotFound:        http.StatusNotFound,
+	fault.InvalidArgument: http.StatusBadRequest,
+	fault.Conflict:        http.StatusConflict,
+	fault.Unavailable:     http.StatusServiceUnavailable,
+	fault.Unauthorized:    http.StatusUnauthorized,
+	fault.Forbidden:       http.StatusForbidden,
+}
+
+// statusCode picks the HTTP status reporting err to the client.
+func statusCode(err error) int {
+	if status, exist := statusByKind[fault.KindOf(err)]; exist {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// errInternal is the message of the errors outside of the fault taxonomy.
+var errInternal = errors.New("internal_error")
+
+// toError converts err into the response.Error sent to API consumers,
+// exposing validation violations as field causes. Errors outside of the fault
+// taxonomy are not meant for consumers: they get a generic internal_error and the
+// cause is logged.
+func toError(r *http.Request, lg *logger.Logger, err error) *response.Error {
+	var domain *fault.Error
+	if !errors.As(err, &domain) {
+		lg.Error(r.Context(), "unexpected error", logger.Err(err))
+		return response.Wrap(http.StatusInternalServerError, errInternal)
+	}
+	e := response.Wrap(statusCode(err), err)
+	for _, v := range fault.ViolationsOf(err) {
+		e.WithCause(response.FieldCause{Field: v.Field, Rule: v.Rule, Message: v.Message})
+	}
+	return e
+}
+
+// writeError is the single place where handlers report a failure.
+func writeError(w http.ResponseWriter, r *http.Request, lg *logger.Logger, err error) {
+	e := toError(r, lg, err)
+	response.WriteError(w, r, e, e.StatusCode)
+}
+
+// respond is the single place where handlers send a result, see response.Respond.
+// Failures are logged: the client got a 500 error when v can't be encoded, and
+// nothing more can be sent when writing the body failed. A 406 is the client's choice.
+func respond(w http.ResponseWriter, r *http.Request, lg *logger.Logger, v interface{}, code int) {
+	if err := response.Respond(w, r, v, code); err != nil && !errors.Is(err, response.ErrNotAcceptable) {
+		lg.Error(r.Context(), "write response fail", logger.Err(err))
+	}
+}
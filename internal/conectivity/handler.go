@@ -2,38 +2,152 @@ package conectivity
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/fault"
 	"github.com/api_base/internal/domain/model"
-	"github.com/go-chi/chi"
+	"github.com/api_base/tool/logger"
 	"net/http"
 )
 
+const (
+	maxBatchSize = 100
+)
+
 type HandlerFunc interface {
 	Get(w http.ResponseWriter, r *http.Request)
+	GetBatch(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
 }
 
 type Service interface {
-	Get(ctx context.Context, id string) (*model.User, error)
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+	GetBatch(ctx context.Context, ids []model.UserID) ([]model.UserResult, error)
+	Create(ctx context.Context, user model.User) (*model.User, error)
+	Update(ctx context.Context, user model.User) (*model.User, error)
 }
 
 type handler struct {
 	service Service
+	logger  *logger.Logger
 }
 
-func NewHandlerFunc(srv Service) HandlerFunc {
-	return &handler{service: srv}
+type batchRequest struct {
+	Ids []model.UserID `json:"ids"`
+}
+
+type userRequest struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+type batchResult struct {
+	Id    model.UserID    `json:"id"`
+	User  *model.User     `json:"user,omitempty"`
+	Error *response.Error `json:"error,omitempty"`
+}
+
+func NewHandlerFunc(srv Service, lg *logger.Logger) HandlerFunc {
+	return &handler{service: srv, logger: lg}
 }
 
 func (h handler) Get(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		response.Write(w, errors.New("invalid_id"), http.StatusBadRequest)
+	id, err := userIDParam(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
 	}
 	user, err := h.service.Get(ctx, id)
 	if err != nil {
-		response.Write(w, err, http.StatusInternalServerError)
+		writeError(w, r, h.logger, err)
+		return
+	}
+	respond(w, r, h.logger, user, http.StatusOK)
+}
+
+// GetBatch resolves up to maxBatchSize users in one call. Every requested id gets
+// an entry in the response, holding either the user or the error found for it.
+func (h handler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req := batchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, h.logger, fault.Wrap(fault.InvalidArgument, "invalid_body", err))
+		return
+	}
+	if violations := validateBatch(req); len(violations) > 0 {
+		writeError(w, r, h.logger, fault.Invalid("invalid_ids", violations...))
+		return
+	}
+	results, err := h.service.GetBatch(ctx, req.Ids)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	resp := make([]batchResult, len(results))
+	for i, result := range results {
+		resp[i] = batchResult{Id: result.Id, User: result.User}
+		if result.Err != nil {
+			resp[i].Error = toError(r, h.logger, result.Err)
+		}
+	}
+	respond(w, r, h.logger, resp, http.StatusOK)
+}
+
+// Create stores the user of the body and answers it with its new id.
+func (h handler) Create(w http.ResponseWriter, r *http.Request) {
+	req := userRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, h.logger, fault.Wrap(fault.InvalidArgument, "invalid_body", err))
+		return
+	}
+	user, err := h.service.Create(r.Context(), model.User{Name: req.Name, Roles: req.Roles})
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	respond(w, r, h.logger, user, http.StatusCreated)
+}
+
+// Update replaces the name and roles of the user of the path with the ones of the body.
+func (h handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	req := userRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, h.logger, fault.Wrap(fault.InvalidArgument, "invalid_body", err))
+		return
+	}
+	user, err := h.service.Update(r.Context(), model.User{Id: id, Name: req.Name, Roles: req.Roles})
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	respond(w, r, h.logger, user, http.StatusOK)
+}
+
+func validateBatch(req batchRequest) []fault.Violation {
+	if len(req.Ids) == 0 || len(req.Ids) > maxBatchSize {
+		return []fault.Violation{{
+			Field:   "ids",
+			Rule:    "size",
+			Message: fmt.Sprintf("ids must contain between 1 and %d elements", maxBatchSize),
+		}}
+	}
+	var violations []fault.Violation
+	for i, id := range req.Ids {
+		if !id.Valid() {
+			violations = append(violations, fault.Violation{
+				Field:   fmt.Sprintf("ids[%d]", i),
+				Rule:    "positive_integer",
+				Message: "id must be a positive integer",
+			})
+		}
 	}
-	response.Write(w, user, http.StatusOK)
+	return violations
 }
@@ -0,0 +1,212 @@
+package conectivity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type serviceMock struct {
+	mock.Mock
+}
+
+func (sm *serviceMock) Get(ctx context.Context, id model.UserID) (*model.User, error) {
+	args := sm.Called(ctx, id)
+	res, _ := args.Get(0).(*model.User)
+	return res, args.Error(1)
+}
+
+func (sm *serviceMock) GetBatch(ctx context.Context, ids []model.UserID) ([]model.UserResult, error) {
+	args := sm.Called(ctx, ids)
+	res, _ := args.Get(0).([]model.UserResult)
+	return res, args.Error(1)
+}
+
+func (sm *serviceMock) Create(ctx context.Context, user model.User) (*model.User, error) {
+	args := sm.Called(ctx, user)
+	res, _ := args.Get(0).(*model.User)
+	return res, args.Error(1)
+}
+
+func (sm *serviceMock) Update(ctx context.Context, user model.User) (*model.User, error) {
+	args := sm.Called(ctx, user)
+	res, _ := args.Get(0).(*model.User)
+	return res, args.Error(1)
+}
+
+func testRouter(h HandlerFunc) http.Handler {
+	rh := &routerHandler{handlerFunc: h, logger: logger.Discard(), metrics: metrics.NewRegistry()}
+	return rh.Handler()
+}
+
+func doRequest(h HandlerFunc, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	testRouter(h).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestHandler_Get(t *testing.T) {
+	srv := &serviceMock{}
+	srv.On("Get", mock.Anything, model.UserID(7)).Return(&model.User{Id: 7, Name: "seven"}, nil)
+
+	rec := doRequest(NewHandlerFunc(srv, logger.Discard()), http.MethodGet, "/get/7")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":7,"name":"seven","token":{"token":"","user_id":""}}`, rec.Body.String())
+}
+
+func TestHandler_Get_NonNumericId(t *testing.T) {
+	srv := &serviceMock{}
+
+	rec := doRequest(NewHandlerFunc(srv, logger.Discard()), http.MethodGet, "/get/abc")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"message":"invalid_user_id","error":"bad_request","status":400,"cause":[
+		{"field":"id","rule":"positive_integer","message":"id must be a positive integer"}
+	]}`, rec.Body.String())
+	srv.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestHandler_Get_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{model.ErrUserNotFound, http.StatusNotFound, "not_found", model.ErrUserNotFound.Error()},
+		{fault.New(fault.Unavailable, "token_api_unavailable"), http.StatusServiceUnavailable, "service_unavailable", "token_api_unavailable"},
+		{fault.New(fault.Unauthorized, "token_api_unauthorized"), http.StatusUnauthorized, "unauthorized", "token_api_unauthorized"},
+		{fault.New(fault.Conflict, "duplicated"), http.StatusConflict, "conflict", "duplicated"},
+		{errors.New("dial tcp 10.0.0.5:3306: connection refused"), http.StatusInternalServerError, "internal_server_error", "internal_error"},
+	}
+	for _, tt := range tests {
+		srv := &serviceMock{}
+		srv.On("Get", mock.Anything, model.UserID(7)).Return(nil, tt.err)
+
+		rec := doRequest(NewHandlerFunc(srv, logger.Discard()), http.MethodGet, "/get/7")
+
+		assert.Equal(t, tt.status, rec.Code)
+		body := response.Error{}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body), "a single json body is written")
+		assert.Equal(t, tt.code, body.Code)
+		assert.Equal(t, tt.message, body.Message)
+	}
+}
+
+func TestHandler_Get_LogsUnexpectedErrors(t *testing.T) {
+	out := &strings.Builder{}
+	lg, _ := logger.New(out, logger.Config{})
+	srv := &serviceMock{}
+	srv.On("Get", mock.Anything, model.UserID(7)).Return(nil, errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+
+	rec := doRequest(NewHandlerFunc(srv, lg), http.MethodGet, "/get/7")
+
+	assert.NotContains(t, rec.Body.String(), "10.0.0.5", "causes don't reach clients")
+	assert.Contains(t, out.String(), `"msg":"unexpected error"`)
+	assert.Contains(t, out.String(), "dial tcp 10.0.0.5:3306: connection refused")
+}
+
+func TestHandler_GetBatch(t *testing.T) {
+	srv := &serviceMock{}
+	srv.On("GetBatch", mock.Anything, []model.UserID{1, 2}).Return([]model.UserResult{
+		{Id: 1, User: &model.User{Id: 1, Name: "one"}},
+		{Id: 2, Err: model.ErrUserNotFound},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/batch-get", strings.NewReader(`{"ids":[1,2]}`))
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"id":1,"user":{"id":1,"name":"one","token":{"token":"","user_id":""}}},
+		{"id":2,"error":{"message":"user_not_found","error":"not_found","status":404,"cause":null}}
+	]`, rec.Body.String())
+}
+
+func TestHandler_GetBatch_InvalidIds(t *testing.T) {
+	for _, body := range []string{`{"ids":[]}`, `{"ids":[0]}`, `{"ids":["a"]}`, `not json`} {
+		srv := &serviceMock{}
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/users/batch-get", strings.NewReader(body))
+		testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		srv.AssertNotCalled(t, "GetBatch", mock.Anything, mock.Anything)
+	}
+}
+
+func TestHandler_GetBatch_ValidationCauses(t *testing.T) {
+	srv := &serviceMock{}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users/batch-get", strings.NewReader(`{"ids":[1,0,-3]}`))
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"message":"invalid_ids","error":"bad_request","status":400,"cause":[
+		{"field":"ids[1]","rule":"positive_integer","message":"id must be a positive integer"},
+		{"field":"ids[2]","rule":"positive_integer","message":"id must be a positive integer"}
+	]}`, rec.Body.String())
+}
+
+func TestHandler_Create(t *testing.T) {
+	srv := &serviceMock{}
+	srv.On("Create", mock.Anything, model.User{Name: "new", Roles: []string{"user"}}).Return(&model.User{Id: 3, Name: "new", Roles: []string{"user"}}, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"new","roles":["user"]}`))
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":3,"name":"new","roles":["user"],"token":{"token":"","user_id":""}}`, rec.Body.String())
+}
+
+func TestHandler_Update(t *testing.T) {
+	srv := &serviceMock{}
+	srv.On("Update", mock.Anything, model.User{Id: 7, Name: "renamed"}).Return(&model.User{Id: 7, Name: "renamed"}, nil)
+	srv.On("Update", mock.Anything, model.User{Id: 8, Name: "renamed"}).Return(nil, model.ErrUserNotFound)
+
+	rec := httptest.NewRecorder()
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{"name":"renamed"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"renamed"`)
+
+	rec = httptest.NewRecorder()
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/8", strings.NewReader(`{"name":"renamed"}`)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	testRouter(NewHandlerFunc(srv, logger.Discard())).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/7", strings.NewReader(`{`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRespond_LogsFailures(t *testing.T) {
+	out := &strings.Builder{}
+	lg, _ := logger.New(out, logger.Config{})
+	rec := httptest.NewRecorder()
+
+	respond(rec, httptest.NewRequest(http.MethodGet, "/", nil), lg, make(chan int), http.StatusOK)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, out.String(), `"msg":"write response fail"`)
+
+	out.Reset()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html")
+	respond(httptest.NewRecorder(), req, lg, model.User{}, http.StatusOK)
+	assert.Empty(t, out.String(), "a 406 is not a failure of the api")
+}
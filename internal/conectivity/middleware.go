@@ -1,3 +1,324 @@
 package conectivity
 
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/api_base/tool/ratelimit"
+	"github.com/api_base/tool/tracing"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
 
+const (
+	RequestIDHeader    = "X-Request-Id"
+	maxRequestIDLength = 128
+	bearerPrefix       = "bearer "
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+)
+
+// RequestID propagates the X-Request-Id header of the request, or generates a new id
+// when it is missing or malformed, into the request context and the response headers.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = logger.WithFields(ctx, logger.F("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the id assigned by RequestID to the request of ctx.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// AccessLog logs one entry per request once the request is served. The route is the
+// chi route pattern, so requests for different ids share it.
+func AccessLog(lg *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			lg.Info(r.Context(), "request",
+				logger.F("method", r.Method),
+				logger.F("route", routePattern(r)),
+				logger.F("path", r.URL.Path),
+				logger.F("status", status),
+				logger.F("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+				logger.F("bytes", ww.BytesWritten()),
+			)
+		})
+	}
+}
+
+// Tracing starts a server span per request, continuing the trace of the incoming W3C
+// traceparent header. The span is named after the method and chi route pattern.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, ok := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader)); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		ctx, span := tracing.Start(ctx, r.Method, tracing.KindServer)
+		ctx = logger.WithFields(ctx, logger.F("trace_id", span.Context().TraceID.String()))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("http.request_id", RequestIDFromContext(ctx))
+		var err error
+		if status >= http.StatusInternalServerError {
+			err = errors.New(http.StatusText(status))
+		}
+		span.End(err)
+	})
+}
+
+// unmatchedRoute labels the requests no route matched, so arbitrary paths don't create series.
+const unmatchedRoute = "unmatched"
+
+// Metrics counts the requests and observes their latency in registry, by method, chi
+// route pattern and status.
+func Metrics(registry *metrics.Registry) func(http.Handler) http.Handler {
+	requests := registry.Counter("http_requests_total", "HTTP requests served.", "method", "route", "status")
+	latency := registry.Histogram("http_request_duration_seconds", "HTTP request latency in seconds.", metrics.DefaultBuckets, "method", "route", "status")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			code := strconv.Itoa(status)
+			requests.Inc(r.Method, route, code)
+			latency.Observe(time.Since(start).Seconds(), r.Method, route, code)
+		})
+	}
+}
+
+// Recoverer turns a panic of the handler chain into a 500 response.Error, logging
+// the panic value and the stack trace. When the handler already wrote the response
+// headers the response is left as is, only the panic is logged.
+func Recoverer(lg *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				lg.Error(r.Context(), "panic",
+					logger.F("method", r.Method),
+					logger.F("route", routePattern(r)),
+					logger.F("path", r.URL.Path),
+					logger.F("panic", fmt.Sprint(rvr)),
+					logger.F("stack", string(debug.Stack())),
+				)
+				if ww.Status() == 0 {
+					response.WriteError(ww, r, response.NewError(http.StatusInternalServerError, "internal_error"), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
+
+// Authenticate rejects with 401 the requests without a valid "Authorization: Bearer" credential.
+// The principal resolved by a is placed into the request context, see auth.FromContext.
+func Authenticate(a auth.Authenticator, lg *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credential, ok := bearerCredential(r)
+			if !ok {
+				writeUnauthorized(w, r, lg, fault.New(fault.Unauthorized, "missing_bearer_token"))
+				return
+			}
+			p, err := a.Authenticate(r.Context(), credential)
+			if err != nil {
+				writeUnauthorized(w, r, lg, err)
+				return
+			}
+			ctx := logger.WithFields(auth.NewContext(r.Context(), p), logger.F("user_id", p.UserID.Int64()))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerCredential returns the credential of the bearer Authorization header of r.
+func bearerCredential(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):]), true
+}
+
+// Authorize rejects with 403 the requests whose principal lacks perm according to policy,
+// every request when policy is nil.
+// When ownerParam is set, the URL param names the user owning the resource, which lets
+// grants restricted to the principal's own resources apply. It must run after Authenticate.
+func Authorize(policy *auth.Policy, perm auth.Permission, ownerParam string, lg *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.FromContext(r.Context())
+			if !ok {
+				writeUnauthorized(w, r, lg, auth.ErrInvalidCredentials)
+				return
+			}
+			var owner model.UserID
+			if ownerParam != "" {
+				owner, _ = model.ParseUserID(chi.URLParam(r, ownerParam))
+			}
+			if !policy.Allowed(p, perm, owner) {
+				writeError(w, r, lg, fault.New(fault.Forbidden, "access_denied"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, lg *logger.Logger, err error) {
+	if fault.Is(err, fault.Unauthorized) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api_base"`)
+	}
+	writeError(w, r, lg, err)
+}
+
+// RateLimit rejects with 429 the requests of a client exceeding the limit of route.
+// X-RateLimit-* headers report the state of the client bucket. When the store fails
+// requests are let through, an unavailable limiter must not take the api down.
+func RateLimit(limiter *ratelimit.Limiter, route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, unlimited, err := limiter.Allow(r.Context(), route, clientKey(r, limiter.KeyKind()))
+			if err != nil || unlimited {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				response.WriteError(w, r, response.NewErrorf(http.StatusTooManyRequests, "rate limit exceeded, retry in %d seconds", retryAfter), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client of r by kind, falling back to its IP address when
+// the request lacks the identity. Only authenticated requests are identified by their
+// key or user: a client could otherwise get a fresh bucket by sending a new, invalid
+// key with every request. Keys are hashed so credentials are never stored.
+func clientKey(r *http.Request, kind string) string {
+	p, authenticated := auth.FromContext(r.Context())
+	switch kind {
+	case ratelimit.KeyAPIKey:
+		if credential, ok := bearerCredential(r); ok && authenticated {
+			sum := sha256.Sum256([]byte(credential))
+			return "key:" + hex.EncodeToString(sum[:])
+		}
+	case ratelimit.KeyUser:
+		if authenticated {
+			return "user:" + p.UserID.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,303 @@
+package conectivity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/api_base/tool/ratelimit"
+	"github.com/api_base/tool/tracing"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func newMiddlewareRouter(out *bytes.Buffer) *chi.Mux {
+	lg, _ := logger.New(out, logger.Config{})
+	r := chi.NewRouter()
+	r.Use(RequestID, AccessLog(lg), Recoverer(lg))
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Request-Id", RequestIDFromContext(r.Context()))
+		_, _ = w.Write([]byte("hello"))
+	})
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	r.Get("/panic/partial", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	})
+	return r
+}
+
+func TestRequestID_Generated(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	newMiddlewareRouter(&bytes.Buffer{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+
+	id := rec.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, rec.Header().Get("X-Seen-Request-Id"))
+}
+
+func TestRequestID_Propagated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(RequestIDHeader, "upstream-id-1")
+
+	newMiddlewareRouter(&bytes.Buffer{}).ServeHTTP(rec, req)
+
+	assert.Equal(t, "upstream-id-1", rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, "upstream-id-1", rec.Header().Get("X-Seen-Request-Id"))
+}
+
+func TestRequestID_RejectsMalformed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(RequestIDHeader, "bad id\twith spaces")
+
+	newMiddlewareRouter(&bytes.Buffer{}).ServeHTTP(rec, req)
+
+	assert.NotEqual(t, "bad id\twith spaces", rec.Header().Get(RequestIDHeader))
+}
+
+func TestAccessLog(t *testing.T) {
+	out := &bytes.Buffer{}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+
+	newMiddlewareRouter(out).ServeHTTP(rec, req)
+
+	entry := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, http.MethodGet, entry["method"])
+	assert.Equal(t, "/items/{id}", entry["route"])
+	assert.Equal(t, "/items/42", entry["path"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.Equal(t, float64(5), entry["bytes"])
+	assert.True(t, entry["latency_ms"].(float64) >= 0)
+}
+
+func TestRecoverer(t *testing.T) {
+	out := &bytes.Buffer{}
+	rec := httptest.NewRecorder()
+
+	newMiddlewareRouter(out).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"message":"internal_error","error":"internal_server_error","status":500,"cause":null}`, rec.Body.String())
+
+	dec := json.NewDecoder(out)
+	panicEntry, accessEntry := map[string]interface{}{}, map[string]interface{}{}
+	assert.Nil(t, dec.Decode(&panicEntry))
+	assert.Nil(t, dec.Decode(&accessEntry))
+	assert.Equal(t, "error", panicEntry["level"])
+	assert.Equal(t, "boom", panicEntry["panic"])
+	assert.Contains(t, panicEntry["stack"], "runtime/debug.Stack")
+	assert.Equal(t, "/panic", accessEntry["route"])
+	assert.Equal(t, float64(http.StatusInternalServerError), accessEntry["status"])
+}
+
+func TestRecoverer_AfterPartialWrite(t *testing.T) {
+	out := &bytes.Buffer{}
+	rec := httptest.NewRecorder()
+
+	newMiddlewareRouter(out).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic/partial", nil))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "partial", rec.Body.String(), "the written response is not followed by an error")
+	panicEntry := map[string]interface{}{}
+	assert.Nil(t, json.NewDecoder(out).Decode(&panicEntry))
+	assert.Equal(t, "boom", panicEntry["panic"])
+}
+
+type authenticatorMock struct {
+	mock.Mock
+}
+
+func (am *authenticatorMock) Authenticate(ctx context.Context, credential string) (auth.Principal, error) {
+	args := am.Called(ctx, credential)
+	return args.Get(0).(auth.Principal), args.Error(1)
+}
+
+func newAuthRouter(a auth.Authenticator) *chi.Mux {
+	policy := auth.NewPolicy(auth.PolicyConfig{
+		Roles:        map[string][]string{"admin": {"users:read"}, "user": {"users:read:self"}},
+		DefaultRoles: []string{"user"},
+	})
+	r := chi.NewRouter()
+	r.With(Authenticate(a, logger.Discard()), Authorize(policy, auth.UsersRead, "id", logger.Discard())).Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.FromContext(r.Context())
+		_, _ = w.Write([]byte(p.UserID.String()))
+	})
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "good").Return(auth.Principal{UserID: 7}, nil)
+	a.On("Authenticate", mock.Anything, "bad").Return(auth.Principal{}, auth.ErrInvalidCredentials)
+	a.On("Authenticate", mock.Anything, "admin").Return(auth.Principal{UserID: 1, Roles: []string{"admin"}}, nil)
+	a.On("Authenticate", mock.Anything, "down").Return(auth.Principal{}, fault.New(fault.Unavailable, "token_api_unavailable"))
+
+	tests := []struct {
+		header string
+		path   string
+		status int
+		body   string
+	}{
+		{"", "/users/7", http.StatusUnauthorized, ""},
+		{"Basic Zm9vOmJhcg==", "/users/7", http.StatusUnauthorized, ""},
+		{"Bearer ", "/users/7", http.StatusUnauthorized, ""},
+		{"Bearer bad", "/users/7", http.StatusUnauthorized, ""},
+		{"Bearer down", "/users/7", http.StatusServiceUnavailable, ""},
+		{"Bearer good", "/users/8", http.StatusForbidden, ""},
+		{"bearer good", "/users/7", http.StatusOK, "7"},
+		{"Bearer admin", "/users/8", http.StatusOK, "1"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Authorization", tt.header)
+
+		newAuthRouter(a).ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.status, rec.Code, tt.header)
+		if tt.status == http.StatusOK {
+			assert.Equal(t, tt.body, rec.Body.String(), "the handler sees the principal of "+tt.header)
+		}
+		if tt.status == http.StatusUnauthorized {
+			assert.Equal(t, `Bearer realm="api_base"`, rec.Header().Get("WWW-Authenticate"), tt.header)
+		}
+	}
+}
+
+func TestAuthorize_NilPolicyDenies(t *testing.T) {
+	r := chi.NewRouter()
+	r.With(Authorize(nil, auth.UsersRead, "id", logger.Discard())).Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{UserID: 7, Roles: []string{"admin"}}))
+
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Config{Enabled: true, Key: ratelimit.KeyAPIKey, Default: ratelimit.Limit{Rate: 0.5, Burst: 1}}, ratelimit.NewMemoryStore())
+	r := chi.NewRouter()
+	r.With(RateLimit(limiter, "GET /items")).Get("/items", func(w http.ResponseWriter, r *http.Request) {})
+	send := func(userID model.UserID, apiKey string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{UserID: userID}))
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(1, "key-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", rec.Header().Get("X-RateLimit-Reset"))
+
+	rec = send(1, "key-1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	body := response.Error{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "too_many_requests", body.Code)
+
+	assert.Equal(t, http.StatusOK, send(1, "key-2").Code, "each key of the user has its own bucket")
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, ratelimit.KeyIP))
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, ratelimit.KeyAPIKey))
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, ratelimit.KeyUser))
+
+	req.Header.Set("Authorization", "Bearer abc")
+	assert.Equal(t, "ip:10.0.0.1", clientKey(req, ratelimit.KeyAPIKey), "an unauthenticated api key is not an identity")
+
+	req = req.WithContext(auth.NewContext(req.Context(), auth.Principal{UserID: 9}))
+	assert.Equal(t, "key:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", clientKey(req, ratelimit.KeyAPIKey))
+	assert.Equal(t, "user:9", clientKey(req, ratelimit.KeyUser))
+}
+
+func TestMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	rh := &routerHandler{handlerFunc: NewHandlerFunc(&serviceMock{}, logger.Discard()), logger: logger.Discard(), metrics: registry}
+	router := rh.Handler()
+	for _, path := range []string{"/get/abc", "/get/xyz", "/unknown/1"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="GET",route="/get/{id}",status="400"} 2`)
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, rec.Body.String(), `http_request_duration_seconds_count{method="GET",route="/get/{id}",status="400"} 2`)
+}
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (sr *spanRecorder) Export(span tracing.SpanData) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.spans = append(sr.spans, span)
+}
+
+func (sr *spanRecorder) Shutdown(context.Context) error { return nil }
+
+func TestTracing(t *testing.T) {
+	recorder := &spanRecorder{}
+	tracing.SetTracer(tracing.NewTracer(recorder, 1))
+	defer tracing.SetTracer(tracing.NewTracer(tracing.NoopExporter{}, 0))
+	r := chi.NewRouter()
+	r.Use(Tracing)
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "handler", tracing.KindInternal)
+		span.End(nil)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/items/7", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Len(t, recorder.spans, 2)
+	child, server := recorder.spans[0], recorder.spans[1]
+	assert.Equal(t, "GET /items/{id}", server.Name)
+	assert.Equal(t, tracing.KindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.Context.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.String())
+	assert.Equal(t, server.Context.SpanID, child.Parent)
+	assert.Equal(t, 500, server.Attributes["http.status_code"])
+	assert.Equal(t, "/items/7", server.Attributes["http.target"])
+	assert.Equal(t, "Internal Server Error", server.Error)
+}
@@ -0,0 +1,72 @@
+package conectivity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"io"
+	"net/http"
+)
+
+type OnboardingHandlerFunc interface {
+	Get(w http.ResponseWriter, r *http.Request)
+	Advance(w http.ResponseWriter, r *http.Request)
+}
+
+// OnboardingService moves users through the onboarding steps, see onboarding.Service.
+type OnboardingService interface {
+	Get(ctx context.Context, userID model.UserID) (model.Onboarding, error)
+	Advance(ctx context.Context, userID model.UserID, step string) (model.Onboarding, error)
+}
+
+type onboardingHandler struct {
+	onboarding OnboardingService
+	logger     *logger.Logger
+}
+
+type onboardingRequest struct {
+	Step string `json:"step"`
+}
+
+func NewOnboardingHandlerFunc(onboarding OnboardingService, lg *logger.Logger) OnboardingHandlerFunc {
+	return &onboardingHandler{onboarding: onboarding, logger: lg}
+}
+
+// Get returns the onboarding step of the user of the path and its history.
+func (h onboardingHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	onboarding, err := h.onboarding.Get(r.Context(), id)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	respond(w, r, h.logger, onboarding, http.StatusOK)
+}
+
+// Advance moves the user of the path to the step of the body, or to the next step
+// when the body is empty.
+func (h onboardingHandler) Advance(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	body := onboardingRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, r, h.logger, fault.Wrap(fault.InvalidArgument, "invalid_body", err))
+		return
+	}
+	onboarding, err := h.onboarding.Advance(r.Context(), id, body.Step)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	respond(w, r, h.logger, onboarding, http.StatusOK)
+}
@@ -0,0 +1,84 @@
+package conectivity
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type onboardingServiceMock struct {
+	mock.Mock
+}
+
+func (om *onboardingServiceMock) Get(ctx context.Context, userID model.UserID) (model.Onboarding, error) {
+	args := om.Called(ctx, userID)
+	return args.Get(0).(model.Onboarding), args.Error(1)
+}
+
+func (om *onboardingServiceMock) Advance(ctx context.Context, userID model.UserID, step string) (model.Onboarding, error) {
+	args := om.Called(ctx, userID, step)
+	return args.Get(0).(model.Onboarding), args.Error(1)
+}
+
+func doOnboardingRequest(onboarding OnboardingService, a auth.Authenticator, method, path, body string) *httptest.ResponseRecorder {
+	policy := auth.NewPolicy(auth.PolicyConfig{
+		Roles: map[string][]string{
+			"admin": {"users:read", "onboarding:write"},
+			"user":  {"users:read:self", "onboarding:write:self"},
+		},
+		DefaultRoles: []string{"user"},
+	})
+	rh := &routerHandler{
+		handlerFunc:    NewHandlerFunc(&serviceMock{}, logger.Discard()),
+		onboardingFunc: NewOnboardingHandlerFunc(onboarding, logger.Discard()),
+		security:       Security{Authenticator: a, Policy: policy},
+		logger:         logger.Discard(),
+		metrics:        metrics.NewRegistry(),
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer opaque")
+	rh.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOnboardingHandler_Get(t *testing.T) {
+	onboarding, a := &onboardingServiceMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+	onboarding.On("Get", mock.Anything, model.UserID(7)).Return(model.Onboarding{
+		UserID: 7, Step: "created", NextStep: "profile_completed", History: []model.OnboardingTransition{},
+	}, nil)
+
+	rec := doOnboardingRequest(onboarding, a, http.MethodGet, "/users/7/onboarding", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"user_id":7,"step":"created","next_step":"profile_completed","completed":false,"history":[]}`, rec.Body.String())
+	rec = doOnboardingRequest(onboarding, a, http.MethodGet, "/users/8/onboarding", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestOnboardingHandler_Advance(t *testing.T) {
+	onboarding, a := &onboardingServiceMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 1, Roles: []string{"admin"}}, nil)
+	onboarding.On("Advance", mock.Anything, model.UserID(7), "").Return(model.Onboarding{UserID: 7, Step: "profile_completed"}, nil)
+	onboarding.On("Advance", mock.Anything, model.UserID(7), "active").Return(model.Onboarding{}, fault.New(fault.Conflict, "invalid_transition"))
+
+	rec := doOnboardingRequest(onboarding, a, http.MethodPost, "/users/7/onboarding", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"step":"profile_completed"`)
+
+	rec = doOnboardingRequest(onboarding, a, http.MethodPost, "/users/7/onboarding", `{"step":"active"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doOnboardingRequest(onboarding, a, http.MethodPost, "/users/7/onboarding", `{"step":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
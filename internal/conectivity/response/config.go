@@ -0,0 +1,48 @@
+package response
+
+import (
+	"github.com/api_base/tool/validation"
+	"net/url"
+	"sync"
+)
+
+// Config response rendering config
+type Config struct {
+	ProblemDetails ProblemConfig `yaml:"problem_details"`
+}
+
+// ProblemConfig enables RFC 7807 error bodies for clients asking for application/problem+json.
+// TypeBaseUri is prefixed to the error code to build the problem type, "about:blank" is used when empty.
+type ProblemConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	TypeBaseUri string `yaml:"type_base_uri"`
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if base := c.ProblemDetails.TypeBaseUri; base != "" {
+		if u, err := url.Parse(base); err != nil || !u.IsAbs() {
+			problems.Addf("problem_details.type_base_uri", "must be an absolute uri, got %q", base)
+		}
+	}
+	return problems.Err()
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// Configure sets the config used by every response written from now on.
+func Configure(cfg Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+}
+
+func currentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
@@ -0,0 +1,223 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var errNotEncodable = errors.New("value_not_encodable")
+
+// Encoder serializes a Go value into a media type.
+type Encoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// Supporter is implemented by encoders that can only serialize some values,
+// e.g. CSV only encodes lists. Negotiation skips encoders not supporting the value.
+type Supporter interface {
+	Supports(v interface{}) bool
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+func (f EncoderFunc) Encode(w io.Writer, v interface{}) error {
+	return f(w, v)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// xmlEncoder writes the JSON representation of a value as XML, so json tags and
+// custom marshalers apply to every format alike. Objects become nested elements,
+// lists repeat an <item> element and the document root is <response>.
+type xmlEncoder struct{}
+
+func (xmlEncoder) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := encodeXMLElement(enc, "response", tree); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for _, key := range sortedKeys(val) {
+			if err := encodeXMLElement(enc, key, val[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if err := encodeXMLElement(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(val))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName replaces the characters not allowed in an element name.
+func xmlName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// csvEncoder writes lists as CSV. Nested objects are flattened into dotted column
+// names and the header holds the union of the columns of every row, sorted.
+type csvEncoder struct{}
+
+func (csvEncoder) Supports(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	kind := reflect.TypeOf(v).Kind()
+	return (kind == reflect.Slice || kind == reflect.Array) && reflect.TypeOf(v).Elem().Kind() != reflect.Uint8
+}
+
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	list, isList := tree.([]interface{})
+	if !isList {
+		return errNotEncodable
+	}
+	rows := make([]map[string]string, len(list))
+	columns := map[string]bool{}
+	for i, item := range list {
+		rows[i] = map[string]string{}
+		flatten("", item, rows[i])
+		for column := range rows[i] {
+			columns[column] = true
+		}
+	}
+	header := make([]string, 0, len(columns))
+	for column := range columns {
+		header = append(header, column)
+	}
+	sort.Strings(header)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, column := range header {
+			record[i] = row[column]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func flatten(prefix string, v interface{}, row map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flatten(name, item, row)
+		}
+	case []interface{}:
+		data, _ := json.Marshal(val)
+		row[columnName(prefix)] = string(data)
+	default:
+		row[columnName(prefix)] = scalarString(val)
+	}
+}
+
+func columnName(prefix string) string {
+	if prefix == "" {
+		return "value"
+	}
+	return prefix
+}
+
+// toTree converts v to its generic JSON representation: nil, bool, json.Number,
+// string, []interface{} and map[string]interface{}.
+func toTree(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func scalarString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		if val {
+			return "true"
+		}
+		return "false"
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
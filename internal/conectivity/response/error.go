@@ -12,6 +12,14 @@ type Error struct {
 	Code       string        `json:"error"`
 	StatusCode int           `json:"status"`
 	Cause      []interface{} `json:"cause"`
+	err        error
+}
+
+// FieldCause describes why a single field of the request was rejected.
+type FieldCause struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 // Error returns a string message of the error. It is a concatenation of Code and Message fields.
@@ -32,6 +40,17 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
 }
 
+// Unwrap returns the error that originated e, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// WithCause appends machine-readable details, such as FieldCause values, to e.
+func (e *Error) WithCause(cause ...interface{}) *Error {
+	e.Cause = append(e.Cause, cause...)
+	return e
+}
+
 // NewError creates a new error with the given status code and message.
 func NewError(statusCode int, message string) error {
 	return NewErrorf(statusCode, message)
@@ -40,9 +59,20 @@ func NewError(statusCode int, message string) error {
 // NewErrorf creates a new error with the given status code and the message
 // formatted according to args and format.
 func NewErrorf(statusCode int, format string, args ...interface{}) error {
+	return newError(statusCode, fmt.Sprintf(format, args...), nil)
+}
+
+// Wrap creates a new error with the given status code whose message is err's message.
+// err stays reachable through errors.Is and errors.As.
+func Wrap(statusCode int, err error) *Error {
+	return newError(statusCode, err.Error(), err)
+}
+
+func newError(statusCode int, message string, err error) *Error {
 	return &Error{
 		Code:       strings.ReplaceAll(strings.ToLower(http.StatusText(statusCode)), " ", "_"),
-		Message:    fmt.Sprintf(format, args...),
+		Message:    message,
 		StatusCode: statusCode,
+		err:        err,
 	}
 }
@@ -0,0 +1,143 @@
+package response
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// msgpackEncoder writes the JSON representation of a value using the MessagePack
+// format (https://github.com/msgpack/msgpack/blob/master/spec.md). Map keys are sorted
+// so the output is deterministic.
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) Encode(w io.Writer, v interface{}) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, 256)
+	buf, err = appendMsgpack(buf, tree)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if val {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
+			return appendMsgpackInt(b, i), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, 0xcb)
+		return appendUint64(b, math.Float64bits(f)), nil
+	case string:
+		return appendMsgpackString(b, val), nil
+	case []interface{}:
+		b = appendMsgpackHeader(b, len(val), 0x90, 0xdc, 0xdd)
+		var err error
+		for _, item := range val {
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		b = appendMsgpackHeader(b, len(val), 0x80, 0xde, 0xdf)
+		var err error
+		for _, key := range sortedKeys(val) {
+			b = appendMsgpackString(b, key)
+			if b, err = appendMsgpack(b, val[key]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		b = append(b, 0xd1)
+		return appendUint16(b, uint16(int16(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		b = append(b, 0xd2)
+		return appendUint32(b, uint32(int32(i)))
+	default:
+		b = append(b, 0xd3)
+		return appendUint64(b, uint64(i))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = appendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = appendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackHeader writes the header of an array or a map holding n elements.
+func appendMsgpackHeader(b []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n <= 15:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, code16)
+		return appendUint16(b, uint16(n))
+	default:
+		b = append(b, code32)
+		return appendUint32(b, uint32(n))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
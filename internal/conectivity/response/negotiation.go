@@ -0,0 +1,99 @@
+package response
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by preference.
+// Ranges with equal quality keep more specific ranges first, as RFC 7231 section 5.3.2 requires.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		slash := strings.Index(mediaType, "/")
+		if slash < 0 {
+			continue
+		}
+		mr := mediaRange{typ: mediaType[:slash], subtype: mediaType[slash+1:], q: 1}
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.typ == "*":
+		return 0
+	case mr.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (mr mediaRange) matches(mediaType string) bool {
+	slash := strings.Index(mediaType, "/")
+	typ, subtype := mediaType[:slash], mediaType[slash+1:]
+	return (mr.typ == "*" || mr.typ == typ) && (mr.subtype == "*" || mr.subtype == subtype)
+}
+
+// quality returns the weight the Accept header gives to mediaType, taken from the
+// most specific range matching it. Zero means the media type is not acceptable.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	best, q := -1, 0.0
+	for _, mr := range ranges {
+		if mr.matches(mediaType) && mr.specificity() > best {
+			best, q = mr.specificity(), mr.q
+		}
+	}
+	return q
+}
+
+// prefersExplicitly reports whether the Accept header names mediaType itself, and
+// ranks it at least as high as every alternative.
+func prefersExplicitly(header, mediaType string, alternatives ...string) bool {
+	ranges := parseAccept(header)
+	named := false
+	for _, mr := range ranges {
+		if mr.specificity() == 2 && mr.matches(mediaType) {
+			named = true
+		}
+	}
+	q := quality(ranges, mediaType)
+	if !named || q <= 0 {
+		return false
+	}
+	for _, alternative := range alternatives {
+		if quality(ranges, alternative) > q {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	defaultProblemType = "about:blank"
+)
+
+// Problem is the RFC 7807 representation of an Error.
+// Extensions are serialized as additional members of the problem object.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblem builds the problem details of e for the request identified by instance.
+func NewProblem(e *Error, instance string) Problem {
+	problemType := defaultProblemType
+	if base := currentConfig().ProblemDetails.TypeBaseUri; base != "" {
+		problemType = base + e.Code
+	}
+	extensions := map[string]interface{}{"error": e.Code}
+	if len(e.Cause) > 0 {
+		extensions["cause"] = e.Cause
+	}
+	return Problem{
+		Type:       problemType,
+		Title:      http.StatusText(e.StatusCode),
+		Status:     e.StatusCode,
+		Detail:     e.Message,
+		Instance:   instance,
+		Extensions: extensions,
+	}
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// WriteError sends err to the client. When problem details are enabled and the request
+// explicitly prefers application/problem+json the RFC 7807 format is used, otherwise the
+// Error is sent in the format negotiated by DefaultRegistry.
+func WriteError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	e := asError(err, statusCode)
+	if !currentConfig().ProblemDetails.Enabled || !prefersExplicitly(r.Header.Get("Accept"), ProblemContentType, DefaultRegistry.MediaTypes()...) {
+		_ = DefaultRegistry.respondError(w, r, e)
+		return
+	}
+	body, mErr := json.Marshal(NewProblem(e, r.URL.RequestURI()))
+	if mErr != nil {
+		_ = RespondJSON(w, e, e.StatusCode)
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(e.StatusCode)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,72 @@
+package response
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withConfig(t *testing.T, cfg Config) {
+	Configure(cfg)
+	t.Cleanup(func() { Configure(Config{}) })
+}
+
+func TestWriteError_ProblemDetails(t *testing.T) {
+	withConfig(t, Config{ProblemDetails: ProblemConfig{Enabled: true, TypeBaseUri: "https://api-base/errors/"}})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/get/abc?verbose=1", nil)
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+	err := Wrap(http.StatusBadRequest, errors.New("invalid_user_id")).
+		WithCause(FieldCause{Field: "id", Rule: "positive_integer", Message: "id must be a positive integer"})
+
+	WriteError(rec, req, err, http.StatusInternalServerError)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type":"https://api-base/errors/bad_request",
+		"title":"Bad Request",
+		"status":400,
+		"detail":"invalid_user_id",
+		"instance":"/get/abc?verbose=1",
+		"error":"bad_request",
+		"cause":[{"field":"id","rule":"positive_integer","message":"id must be a positive integer"}]
+	}`, rec.Body.String())
+}
+
+func TestWriteError_ProblemDetailsDefaultType(t *testing.T) {
+	withConfig(t, Config{ProblemDetails: ProblemConfig{Enabled: true}})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/get/1", nil)
+	req.Header.Set("Accept", "application/problem+json")
+
+	WriteError(rec, req, errors.New("boom"), http.StatusInternalServerError)
+
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"boom","instance":"/get/1","error":"internal_server_error"}`, rec.Body.String())
+}
+
+func TestWriteError_LegacyFormat(t *testing.T) {
+	tests := []struct {
+		enabled bool
+		accept  string
+	}{
+		{false, "application/problem+json"},
+		{true, ""},
+		{true, "*/*"},
+		{true, "application/json"},
+		{true, "application/problem+json;q=0.5, application/json"},
+	}
+	for _, tt := range tests {
+		withConfig(t, Config{ProblemDetails: ProblemConfig{Enabled: tt.enabled}})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/get/1", nil)
+		req.Header.Set("Accept", tt.accept)
+
+		WriteError(rec, req, errors.New("boom"), http.StatusInternalServerError)
+
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), tt.accept)
+		assert.JSONEq(t, `{"message":"boom","error":"internal_server_error","status":500,"cause":null}`, rec.Body.String())
+	}
+}
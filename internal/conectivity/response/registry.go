@@ -0,0 +1,143 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrNotAcceptable is returned by Respond when no registered encoder satisfies the Accept header.
+var ErrNotAcceptable = errors.New("not_acceptable")
+
+// Registry holds the encoders available for content negotiation, by media type.
+type Registry struct {
+	mu         sync.RWMutex
+	mediaTypes []string
+	encoders   map[string]Encoder
+}
+
+// DefaultRegistry is the Registry used by Respond. It serializes to JSON, XML,
+// MessagePack and, for lists, CSV. JSON is used when the request has no preference.
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	reg := NewRegistry()
+	reg.Register("application/json", jsonEncoder{})
+	reg.Register("application/xml", xmlEncoder{})
+	reg.Register("application/msgpack", msgpackEncoder{})
+	reg.Register("application/x-msgpack", msgpackEncoder{})
+	reg.Register("text/csv", csvEncoder{})
+	return reg
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{encoders: map[string]Encoder{}}
+}
+
+// Register makes enc available for mediaType. The first registered media type is
+// the one used when the client accepts anything.
+func (reg *Registry) Register(mediaType string, enc Encoder) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, exist := reg.encoders[mediaType]; !exist {
+		reg.mediaTypes = append(reg.mediaTypes, mediaType)
+	}
+	reg.encoders[mediaType] = enc
+}
+
+// MediaTypes returns the registered media types in registration order.
+func (reg *Registry) MediaTypes() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return append([]string(nil), reg.mediaTypes...)
+}
+
+// Negotiate picks the media type and encoder for v that the Accept header ranks highest.
+// Ties are resolved by registration order.
+func (reg *Registry) Negotiate(accept string, v interface{}) (string, Encoder, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	if accept == "" {
+		accept = "*/*"
+	}
+	ranges := parseAccept(accept)
+	var (
+		bestType string
+		bestEnc  Encoder
+		bestQ    float64
+	)
+	for _, mediaType := range reg.mediaTypes {
+		enc := reg.encoders[mediaType]
+		if s, ok := enc.(Supporter); ok && !s.Supports(v) {
+			continue
+		}
+		if q := quality(ranges, mediaType); q > bestQ {
+			bestType, bestEnc, bestQ = mediaType, enc, q
+		}
+	}
+	return bestType, bestEnc, bestEnc != nil
+}
+
+// Respond serializes v in the format preferred by the request Accept header using
+// DefaultRegistry. When nothing acceptable is available a 406 error is sent instead
+// and ErrNotAcceptable is returned. When v can't be encoded a 500 error is sent instead
+// and the encoding error is returned.
+func Respond(w http.ResponseWriter, r *http.Request, v interface{}, code int) error {
+	return DefaultRegistry.Respond(w, r, v, code)
+}
+
+// Respond is like the package level Respond, negotiating among the encoders of reg.
+func (reg *Registry) Respond(w http.ResponseWriter, r *http.Request, v interface{}, code int) error {
+	if code == http.StatusNoContent || v == nil {
+		w.WriteHeader(code)
+		return nil
+	}
+	mediaType, enc, ok := reg.Negotiate(r.Header.Get("Accept"), v)
+	if !ok {
+		_ = RespondJSON(w, NewError(http.StatusNotAcceptable, "no acceptable representation, available: "+strings.Join(reg.MediaTypes(), ", ")), http.StatusNotAcceptable)
+		return ErrNotAcceptable
+	}
+	body, err := encode(enc, v)
+	if err != nil {
+		_ = RespondJSON(w, NewError(http.StatusInternalServerError, "internal_error"), http.StatusInternalServerError)
+		return err
+	}
+	return send(w, mediaType, body, code)
+}
+
+// respondError sends e in the format preferred by the request. Errors are always
+// delivered: JSON is used when nothing acceptable can encode them.
+func (reg *Registry) respondError(w http.ResponseWriter, r *http.Request, e *Error) error {
+	mediaType, enc, ok := reg.Negotiate(r.Header.Get("Accept"), e)
+	if !ok {
+		return RespondJSON(w, e, e.StatusCode)
+	}
+	return write(w, mediaType, enc, e, e.StatusCode)
+}
+
+func write(w http.ResponseWriter, mediaType string, enc Encoder, v interface{}, code int) error {
+	body, err := encode(enc, v)
+	if err != nil {
+		return err
+	}
+	return send(w, mediaType, body, code)
+}
+
+func encode(enc Encoder, v interface{}) ([]byte, error) {
+	var body bytes.Buffer
+	if err := enc.Encode(&body, v); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func send(w http.ResponseWriter, mediaType string, body []byte, code int) error {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(code)
+	_, err := w.Write(body)
+	return err
+}
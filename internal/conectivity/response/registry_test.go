@@ -0,0 +1,125 @@
+package response
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type item struct {
+	Id    int64             `json:"id"`
+	Name  string            `json:"name"`
+	Owner map[string]string `json:"owner,omitempty"`
+}
+
+func respond(accept string, v interface{}) (*httptest.ResponseRecorder, error) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	err := Respond(rec, req, v, http.StatusOK)
+	return rec, err
+}
+
+func TestRespond_DefaultsToJSON(t *testing.T) {
+	for _, accept := range []string{"", "*/*", "application/*", "text/html, application/json;q=0.1"} {
+		rec, err := respond(accept, item{Id: 1, Name: "one"})
+
+		assert.Nil(t, err, accept)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), accept)
+		assert.JSONEq(t, `{"id":1,"name":"one"}`, rec.Body.String(), accept)
+	}
+}
+
+func TestRespond_XML(t *testing.T) {
+	rec, err := respond("application/xml", []item{{Id: 1, Name: "a&b", Owner: map[string]string{"team": "core"}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><item><id>1</id><name>a&amp;b</name><owner><team>core</team></owner></item></response>`, rec.Body.String())
+}
+
+func TestRespond_MessagePack(t *testing.T) {
+	rec, err := respond("application/msgpack", map[string]interface{}{"id": 1, "neg": -200, "ok": true, "tags": []string{"x"}, "f": 1.5})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/msgpack", rec.Header().Get("Content-Type"))
+	assert.Equal(t, []byte{
+		0x85,
+		0xa1, 'f', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa2, 'i', 'd', 0x01,
+		0xa3, 'n', 'e', 'g', 0xd1, 0xff, 0x38,
+		0xa2, 'o', 'k', 0xc3,
+		0xa4, 't', 'a', 'g', 's', 0x91, 0xa1, 'x',
+	}, rec.Body.Bytes())
+}
+
+func TestRespond_CSV(t *testing.T) {
+	rec, err := respond("text/csv", []item{{Id: 1, Name: "one"}, {Id: 2, Name: "two, too", Owner: map[string]string{"team": "core"}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "id,name,owner.team\n1,one,\n2,\"two, too\",core\n", rec.Body.String())
+}
+
+func TestRespond_CSVOnlyForLists(t *testing.T) {
+	rec, err := respond("text/csv", item{Id: 1})
+
+	assert.Equal(t, ErrNotAcceptable, err)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	rec, err = respond("text/csv, application/json;q=0.5", item{Id: 1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
+
+func TestRespond_NotAcceptable(t *testing.T) {
+	rec, err := respond("text/html, application/json;q=0", item{Id: 1})
+
+	assert.Equal(t, ErrNotAcceptable, err)
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+func TestRespond_EncodeError(t *testing.T) {
+	rec, err := respond("", make(chan int))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"message":"internal_error","error":"internal_server_error","status":500,"cause":null}`, rec.Body.String())
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register("text/plain", EncoderFunc(func(w io.Writer, v interface{}) error {
+		_, err := io.WriteString(w, "plain")
+		return err
+	}))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/*")
+
+	err := reg.Respond(rec, req, item{}, http.StatusCreated)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "plain", rec.Body.String())
+}
+
+func TestWriteError_Negotiated(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/xml")
+
+	WriteError(rec, req, NewError(http.StatusNotFound, "user_not_found"), http.StatusInternalServerError)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<error>not_found</error><message>user_not_found</message>")
+}
@@ -2,20 +2,39 @@ package response
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 )
 
+// Write sends content to the client with the given status code.
+// Errors are rendered as an Error: an *Error found in the chain of content is sent as is,
+// keeping its own status code and causes, any other error is wrapped with statusCode.
 func Write(w http.ResponseWriter, content interface{}, statusCode int) {
 	switch v := content.(type) {
 	case error:
-		_ = RespondJSON(w, NewError(statusCode, v.Error()), statusCode)
+		e := asError(v, statusCode)
+		_ = RespondJSON(w, e, e.StatusCode)
 	default:
 		_ = RespondJSON(w, v, statusCode)
 	}
 }
 
+// asError returns the *Error found in the chain of err or wraps err with statusCode.
+func asError(err error, statusCode int) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return Wrap(statusCode, err)
+	}
+	if e.StatusCode == 0 {
+		withStatus := *e
+		withStatus.StatusCode = statusCode
+		return &withStatus
+	}
+	return e
+}
+
 // RespondJSON converts a Go value to JSON and sends it to the client.
 // If v is nil or code is equal to http.StatusNoContent we avoid writing any content to w.
 // HTTP response header with the provided status code is always set.
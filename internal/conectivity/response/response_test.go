@@ -0,0 +1,43 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite_PlainError(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	Write(rec, errors.New("boom"), http.StatusInternalServerError)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"message":"boom","error":"internal_server_error","status":500,"cause":null}`, rec.Body.String())
+}
+
+func TestWrite_PreservesTypedError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	typed := Wrap(http.StatusUnprocessableEntity, errors.New("invalid_name")).
+		WithCause(FieldCause{Field: "name", Rule: "required", Message: "name is required"})
+
+	Write(rec, fmt.Errorf("create user: %w", typed), http.StatusInternalServerError)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.JSONEq(t, `{"message":"invalid_name","error":"unprocessable_entity","status":422,"cause":[
+		{"field":"name","rule":"required","message":"name is required"}
+	]}`, rec.Body.String())
+}
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := error(Wrap(http.StatusServiceUnavailable, cause))
+
+	var e *Error
+	assert.True(t, errors.As(err, &e))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, "503 service_unavailable: connection refused", err.Error())
+}
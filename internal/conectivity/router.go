@@ -1,23 +1,98 @@
 package conectivity
 
-import "github.com/go-chi/chi"
+import (
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/api_base/tool/ratelimit"
+	"github.com/go-chi/chi"
+	"net/http"
+)
 
 type RouterHandler interface {
 	Handler() *chi.Mux
 }
 
+// Security groups the guards applied to routes. With a nil Authenticator every route
+// is public, with a nil RateLimiter no route is limited.
+type Security struct {
+	Authenticator auth.Authenticator
+	Policy        *auth.Policy
+	RateLimiter   *ratelimit.Limiter
+}
+
 type routerHandler struct {
-	handlerFunc HandlerFunc
+	handlerFunc      HandlerFunc
+	tokenHandlerFunc TokenHandlerFunc
+	onboardingFunc   OnboardingHandlerFunc
+	security         Security
+	logger           *logger.Logger
+	metrics          *metrics.Registry
 }
 
-func NewRouterHandler(hdlFunc HandlerFunc) RouterHandler {
+// NewRouterHandler creates the router of the api. Access token routes are only
+// mounted when tokenHdlFunc is not nil, onboarding routes when onboardingFunc is not nil.
+func NewRouterHandler(hdlFunc HandlerFunc, tokenHdlFunc TokenHandlerFunc, onboardingFunc OnboardingHandlerFunc, security Security, lg *logger.Logger) RouterHandler {
 	return &routerHandler{
-		handlerFunc: hdlFunc,
+		handlerFunc:      hdlFunc,
+		tokenHandlerFunc: tokenHdlFunc,
+		onboardingFunc:   onboardingFunc,
+		security:         security,
+		logger:           lg,
+		metrics:          metrics.DefaultRegistry,
 	}
 }
 
 func (rh routerHandler) Handler() *chi.Mux {
 	r := chi.NewRouter()
-	r.Get("/get/{id}", rh.handlerFunc.Get)
+	r.Use(RequestID, Tracing, AccessLog(rh.logger), Metrics(rh.metrics), Recoverer(rh.logger))
+	r.Method(http.MethodGet, "/metrics", rh.metrics.Handler())
+	rh.handle(r, http.MethodGet, "/get/{id}", rh.handlerFunc.Get, rh.protected(auth.UsersRead, "id"))
+	rh.handle(r, http.MethodPost, "/users/batch-get", rh.handlerFunc.GetBatch, rh.protected(auth.UsersRead, ""))
+	rh.handle(r, http.MethodPost, "/users", rh.handlerFunc.Create, rh.protected(auth.UsersWrite, ""))
+	rh.handle(r, http.MethodPut, "/users/{id}", rh.handlerFunc.Update, rh.protected(auth.UsersWrite, "id"))
+	if rh.tokenHandlerFunc != nil {
+		rh.handle(r, http.MethodGet, "/.well-known/jwks.json", rh.tokenHandlerFunc.JWKS, nil)
+		rh.handle(r, http.MethodPost, "/token", rh.tokenHandlerFunc.Issue, rh.authenticated())
+		rh.handle(r, http.MethodPost, "/users/{id}/tokens", rh.tokenHandlerFunc.Create, rh.protected(auth.TokensWrite, "id"))
+		rh.handle(r, http.MethodPost, "/users/{id}/tokens/rotate", rh.tokenHandlerFunc.Rotate, rh.protected(auth.TokensWrite, "id"))
+		rh.handle(r, http.MethodDelete, "/users/{id}/tokens/{token}", rh.tokenHandlerFunc.Revoke, rh.protected(auth.TokensWrite, "id"))
+	}
+	if rh.onboardingFunc != nil {
+		rh.handle(r, http.MethodGet, "/users/{id}/onboarding", rh.onboardingFunc.Get, rh.protected(auth.UsersRead, "id"))
+		rh.handle(r, http.MethodPost, "/users/{id}/onboarding", rh.onboardingFunc.Advance, rh.protected(auth.OnboardingWrite, "id"))
+	}
 	return r
 }
+
+// handle mounts h guarded by the rate limit of the route and the given guards.
+// Limits keyed by user or api key run after the guards, which resolve the principal;
+// limits keyed by IP run first, so rejected clients don't reach the authenticator.
+func (rh routerHandler) handle(r chi.Router, method, pattern string, h http.HandlerFunc, guards []func(http.Handler) http.Handler) {
+	middlewares := guards
+	if limiter := rh.security.RateLimiter; limiter != nil {
+		limit := RateLimit(limiter, method+" "+pattern)
+		if kind := limiter.KeyKind(); kind == ratelimit.KeyUser || kind == ratelimit.KeyAPIKey {
+			middlewares = append(append([]func(http.Handler) http.Handler{}, guards...), limit)
+		} else {
+			middlewares = append([]func(http.Handler) http.Handler{limit}, guards...)
+		}
+	}
+	r.With(middlewares...).MethodFunc(method, pattern, h)
+}
+
+// authenticated returns the middlewares of routes open to any authenticated principal.
+func (rh routerHandler) authenticated() []func(http.Handler) http.Handler {
+	if rh.security.Authenticator == nil {
+		return nil
+	}
+	return []func(http.Handler) http.Handler{Authenticate(rh.security.Authenticator, rh.logger)}
+}
+
+// protected returns the middlewares of routes requiring perm, see Authorize.
+func (rh routerHandler) protected(perm auth.Permission, ownerParam string) []func(http.Handler) http.Handler {
+	if rh.security.Authenticator == nil {
+		return nil
+	}
+	return append(rh.authenticated(), Authorize(rh.security.Policy, perm, ownerParam, rh.logger))
+}
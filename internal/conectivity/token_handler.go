@@ -0,0 +1,163 @@
+package conectivity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/api_base/internal/conectivity/response"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/domain/token"
+	"github.com/api_base/tool/jwt"
+	"github.com/api_base/tool/logger"
+	"github.com/go-chi/chi"
+	"io"
+	"net/http"
+	"time"
+)
+
+type TokenHandlerFunc interface {
+	Issue(w http.ResponseWriter, r *http.Request)
+	JWKS(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+	Rotate(w http.ResponseWriter, r *http.Request)
+}
+
+type TokenService interface {
+	Issue(ctx context.Context, user model.User, scopes []string) (model.AccessToken, error)
+	JWKS() jwt.JWKS
+}
+
+// TokenLifecycle manages the opaque tokens of users, see token.Lifecycle.
+type TokenLifecycle interface {
+	Create(ctx context.Context, userID model.UserID, req token.Request) (model.IssuedToken, error)
+	Revoke(ctx context.Context, userID model.UserID, tokenID string) error
+	Rotate(ctx context.Context, userID model.UserID, req token.Request) (model.IssuedToken, error)
+}
+
+type tokenHandler struct {
+	tokens    TokenService
+	users     Service
+	lifecycle TokenLifecycle
+	logger    *logger.Logger
+}
+
+type tokenRequest struct {
+	Scopes     []string `json:"scopes"`
+	TTLSeconds int64    `json:"ttl_seconds"`
+}
+
+func NewTokenHandlerFunc(tokens TokenService, users Service, lifecycle TokenLifecycle, lg *logger.Logger) TokenHandlerFunc {
+	return &tokenHandler{tokens: tokens, users: users, lifecycle: lifecycle, logger: lg}
+}
+
+// Issue exchanges the credential of the authenticated user for a signed access token,
+// restricted to the scopes of the credential. Access tokens can't be exchanged, so
+// they can't outlive their TTL nor the revocation of the credential they came from.
+func (h tokenHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, ok := auth.FromContext(ctx)
+	if !ok || p.Method == auth.MethodJWT {
+		writeError(w, r, h.logger, auth.ErrInvalidCredentials)
+		return
+	}
+	user, err := h.users.Get(ctx, p.UserID)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	access, err := h.tokens.Issue(ctx, *user, p.Scopes)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, r, h.logger, access, http.StatusOK)
+}
+
+// JWKS publishes the public keys verifying the issued access tokens.
+func (h tokenHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := response.RespondJSON(w, h.tokens.JWKS(), http.StatusOK); err != nil {
+		h.logger.Error(r.Context(), "write response fail", logger.Err(err))
+	}
+}
+
+// Create issues a new opaque token to the user of the path. Its value is only
+// returned in this response.
+func (h tokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	id, req, err := h.lifecycleRequest(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	issued, err := h.lifecycle.Create(r.Context(), id, req)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, r, h.logger, issued, http.StatusCreated)
+}
+
+// Revoke revokes the token of the path, owned by the user of the path.
+func (h tokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	if err := h.lifecycle.Revoke(r.Context(), id, chi.URLParam(r, "token")); err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Rotate revokes every active token of the user of the path and issues a new one.
+func (h tokenHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	id, req, err := h.lifecycleRequest(r)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	issued, err := h.lifecycle.Rotate(r.Context(), id, req)
+	if err != nil {
+		writeError(w, r, h.logger, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, r, h.logger, issued, http.StatusCreated)
+}
+
+// lifecycleRequest reads the user of the path and the optional body of a request
+// issuing a token.
+func (h tokenHandler) lifecycleRequest(r *http.Request) (model.UserID, token.Request, error) {
+	id, err := userIDParam(r)
+	if err != nil {
+		return 0, token.Request{}, err
+	}
+	body := tokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		return 0, token.Request{}, fault.Wrap(fault.InvalidArgument, "invalid_body", err)
+	}
+	if body.TTLSeconds < 0 {
+		return 0, token.Request{}, fault.Invalid("invalid_ttl", fault.Violation{
+			Field: "ttl_seconds", Rule: "positive_integer", Message: "ttl_seconds must be a positive integer",
+		})
+	}
+	req := token.Request{Scopes: body.Scopes, TTL: time.Duration(body.TTLSeconds) * time.Second}
+	return id, req, nil
+}
+
+func userIDParam(r *http.Request) (model.UserID, error) {
+	id, err := model.ParseUserID(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, fault.Invalid(err.Error(), fault.Violation{
+			Field: "id", Rule: "positive_integer", Message: "id must be a positive integer",
+		})
+	}
+	return id, nil
+}
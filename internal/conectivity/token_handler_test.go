@@ -0,0 +1,231 @@
+package conectivity
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/domain/token"
+	"github.com/api_base/tool/jwt"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tokenServiceMock struct {
+	mock.Mock
+}
+
+func (tm *tokenServiceMock) Issue(ctx context.Context, user model.User, scopes []string) (model.AccessToken, error) {
+	args := tm.Called(ctx, user, scopes)
+	return args.Get(0).(model.AccessToken), args.Error(1)
+}
+
+func (tm *tokenServiceMock) JWKS() jwt.JWKS {
+	return tm.Called().Get(0).(jwt.JWKS)
+}
+
+type lifecycleMock struct {
+	mock.Mock
+}
+
+func (lm *lifecycleMock) Create(ctx context.Context, userID model.UserID, req token.Request) (model.IssuedToken, error) {
+	args := lm.Called(ctx, userID, req)
+	return args.Get(0).(model.IssuedToken), args.Error(1)
+}
+
+func (lm *lifecycleMock) Revoke(ctx context.Context, userID model.UserID, tokenID string) error {
+	return lm.Called(ctx, userID, tokenID).Error(0)
+}
+
+func (lm *lifecycleMock) Rotate(ctx context.Context, userID model.UserID, req token.Request) (model.IssuedToken, error) {
+	args := lm.Called(ctx, userID, req)
+	return args.Get(0).(model.IssuedToken), args.Error(1)
+}
+
+func newTokenRouter(tokens TokenService, users Service, a auth.Authenticator) http.Handler {
+	return newLifecycleRouter(tokens, users, &lifecycleMock{}, a)
+}
+
+func newLifecycleRouter(tokens TokenService, users Service, lifecycle TokenLifecycle, a auth.Authenticator) http.Handler {
+	policy := auth.NewPolicy(auth.PolicyConfig{
+		Roles: map[string][]string{
+			"admin": {"tokens:write"},
+			"user":  {"tokens:write:self"},
+		},
+		DefaultRoles: []string{"user"},
+	})
+	rh := &routerHandler{
+		handlerFunc:      NewHandlerFunc(users, logger.Discard()),
+		tokenHandlerFunc: NewTokenHandlerFunc(tokens, users, lifecycle, logger.Discard()),
+		security:         Security{Authenticator: a, Policy: policy},
+		logger:           logger.Discard(),
+		metrics:          metrics.NewRegistry(),
+	}
+	return rh.Handler()
+}
+
+func TestTokenHandler_Issue(t *testing.T) {
+	tokens, users, a := &tokenServiceMock{}, &serviceMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+	users.On("Get", mock.Anything, model.UserID(7)).Return(&model.User{Id: 7, Name: "seven"}, nil)
+	tokens.On("Issue", mock.Anything, model.User{Id: 7, Name: "seven"}, []string(nil)).Return(model.AccessToken{Token: "a.b.c", Type: "Bearer", ExpiresIn: 900}, nil)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set("Authorization", "Bearer opaque")
+	newTokenRouter(tokens, users, a).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"access_token":"a.b.c","token_type":"Bearer","expires_in":900}`, rec.Body.String())
+}
+
+func TestTokenHandler_Issue_KeepsScopes(t *testing.T) {
+	keys, err := jwt.NewKeySet(jwt.Config{
+		Issuer: "api_base", Audience: "api_base", TTLSeconds: 60, SigningKid: "k1",
+		Keys: []jwt.KeyConfig{{Kid: "k1", Alg: jwt.HS256, Secret: "secret"}},
+	})
+	assert.Nil(t, err)
+	tokens, users, opaque := token.NewService(keys), &serviceMock{}, &authenticatorMock{}
+	opaque.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7, Scopes: []string{"users:read"}}, nil)
+	users.On("Get", mock.Anything, model.UserID(7)).Return(&model.User{Id: 7}, nil)
+	router := newLifecycleRouter(tokens, users, &lifecycleMock{}, token.NewJWTAuthenticator(tokens, opaque))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set("Authorization", "Bearer opaque")
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	access := model.AccessToken{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &access))
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/users/7/tokens", nil)
+	req.Header.Set("Authorization", "Bearer "+access.Token)
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the access token keeps the scopes of the exchanged credential")
+}
+
+func TestTokenHandler_Issue_RefusesAccessTokens(t *testing.T) {
+	keys, err := jwt.NewKeySet(jwt.Config{
+		Issuer: "api_base", Audience: "api_base", TTLSeconds: 60, SigningKid: "k1",
+		Keys: []jwt.KeyConfig{{Kid: "k1", Alg: jwt.HS256, Secret: "secret"}},
+	})
+	assert.Nil(t, err)
+	tokens, users, opaque := token.NewService(keys), &serviceMock{}, &authenticatorMock{}
+	opaque.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7, Method: auth.MethodOpaque}, nil)
+	users.On("Get", mock.Anything, model.UserID(7)).Return(&model.User{Id: 7}, nil)
+	router := newTokenRouter(tokens, users, token.NewJWTAuthenticator(tokens, opaque))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set("Authorization", "Bearer opaque")
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	access := model.AccessToken{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &access))
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/token", nil)
+	req.Header.Set("Authorization", "Bearer "+access.Token)
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "an access token can't be exchanged for a new one")
+	users.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestTokenHandler_Issue_Unauthenticated(t *testing.T) {
+	tokens, users := &tokenServiceMock{}, &serviceMock{}
+
+	rec := httptest.NewRecorder()
+	newTokenRouter(tokens, users, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/token", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	tokens.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTokenHandler_JWKS(t *testing.T) {
+	tokens := &tokenServiceMock{}
+	tokens.On("JWKS").Return(jwt.JWKS{Keys: []jwt.JWK{{Kty: "EC", Kid: "k1", Alg: "ES256", Use: "sig", Crv: "P-256", X: "x", Y: "y"}}})
+
+	rec := httptest.NewRecorder()
+	newTokenRouter(tokens, &serviceMock{}, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"keys":[{"kty":"EC","kid":"k1","alg":"ES256","use":"sig","crv":"P-256","x":"x","y":"y"}]}`, rec.Body.String())
+}
+
+func doLifecycleRequest(lifecycle TokenLifecycle, a auth.Authenticator, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer opaque")
+	newLifecycleRouter(&tokenServiceMock{}, &serviceMock{}, lifecycle, a).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokenHandler_Create(t *testing.T) {
+	lifecycle, a := &lifecycleMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+	expiresAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := token.Request{Scopes: []string{"users:read"}, TTL: time.Hour}
+	lifecycle.On("Create", mock.Anything, model.UserID(7), req).Return(model.IssuedToken{
+		ID: "t1", Token: "at_secret", UserID: 7, Scopes: req.Scopes, Status: model.TokenStatusActive, ExpiresAt: expiresAt,
+	}, nil)
+
+	rec := doLifecycleRequest(lifecycle, a, http.MethodPost, "/users/7/tokens", `{"scopes":["users:read"],"ttl_seconds":3600}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"id":"t1","token":"at_secret","user_id":7,"scopes":["users:read"],"status":"active","expires_at":"2026-01-02T03:04:05Z"}`, rec.Body.String())
+	p, _ := auth.FromContext(lifecycle.Calls[0].Arguments.Get(0).(context.Context))
+	assert.Equal(t, model.UserID(7), p.UserID, "the lifecycle reads the actor from the principal")
+}
+
+func TestTokenHandler_Create_OtherUser(t *testing.T) {
+	lifecycle, a := &lifecycleMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+
+	rec := doLifecycleRequest(lifecycle, a, http.MethodPost, "/users/8/tokens", "")
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	lifecycle.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTokenHandler_Create_InvalidBody(t *testing.T) {
+	lifecycle, a := &lifecycleMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+
+	rec := doLifecycleRequest(lifecycle, a, http.MethodPost, "/users/7/tokens", `{"ttl_seconds":-1}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	lifecycle.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTokenHandler_Revoke(t *testing.T) {
+	lifecycle, a := &lifecycleMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 1, Roles: []string{"admin"}}, nil)
+	lifecycle.On("Revoke", mock.Anything, model.UserID(7), "t1").Return(nil)
+	lifecycle.On("Revoke", mock.Anything, model.UserID(7), "t2").Return(token.ErrTokenNotFound)
+
+	rec := doLifecycleRequest(lifecycle, a, http.MethodDelete, "/users/7/tokens/t1", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = doLifecycleRequest(lifecycle, a, http.MethodDelete, "/users/7/tokens/t2", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTokenHandler_Rotate(t *testing.T) {
+	lifecycle, a := &lifecycleMock{}, &authenticatorMock{}
+	a.On("Authenticate", mock.Anything, "opaque").Return(auth.Principal{UserID: 7}, nil)
+	lifecycle.On("Rotate", mock.Anything, model.UserID(7), token.Request{}).Return(model.IssuedToken{ID: "t2", Token: "at_new", UserID: 7}, nil)
+
+	rec := doLifecycleRequest(lifecycle, a, http.MethodPost, "/users/7/tokens/rotate", "")
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"token":"at_new"`)
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"sync"
+	"time"
+)
+
+var ErrInvalidCredentials = fault.New(fault.Unauthorized, "invalid_credentials")
+
+// TokenFinder resolves the token owning a bearer credential.
+type TokenFinder interface {
+	FindByValue(ctx context.Context, value string) (model.Token, error)
+}
+
+// UserFinder resolves the user owning a token, to know its roles.
+type UserFinder interface {
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+}
+
+// TokenFinders looks a credential up in every finder in turn, until one finds it.
+type TokenFinders []TokenFinder
+
+func (f TokenFinders) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	err := error(fault.New(fault.NotFound, "token_not_found"))
+	for _, finder := range f {
+		var token model.Token
+		token, err = finder.FindByValue(ctx, value)
+		if !fault.Is(err, fault.NotFound) {
+			return token, err
+		}
+	}
+	return model.Token{}, err
+}
+
+// Authenticator resolves bearer credentials into principals.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string) (Principal, error)
+}
+
+type cacheEntry struct {
+	principal Principal
+	expires   time.Time
+}
+
+type tokenAuthenticator struct {
+	tokens TokenFinder
+	users  UserFinder
+	ttl    time.Duration
+	size   int
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewAuthenticator creates an Authenticator validating credentials with tokens and
+// loading the roles of their owner with users. Valid credentials are cached for the
+// configured TTL, so repeated requests of the same client don't reach the token api.
+func NewAuthenticator(tokens TokenFinder, users UserFinder, config Config) Authenticator {
+	ttl := defaultCacheTTL
+	if config.CacheTTLSeconds > 0 {
+		ttl = config.CacheTTLSeconds * time.Second
+	}
+	size := defaultCacheSize
+	if config.CacheSize > 0 {
+		size = config.CacheSize
+	}
+	return &tokenAuthenticator{
+		tokens: tokens,
+		users:  users,
+		ttl:    ttl,
+		size:   size,
+		now:    time.Now,
+		cache:  map[string]cacheEntry{},
+	}
+}
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context, credential string) (Principal, error) {
+	if credential == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if p, ok := a.cached(credential); ok {
+		return p, nil
+	}
+	token, err := a.tokens.FindByValue(ctx, credential)
+	if fault.Is(err, fault.NotFound) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if !token.Active(a.now()) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	userID, err := model.ParseUserID(token.UserId)
+	if err != nil {
+		return Principal{}, ErrInvalidCredentials
+	}
+	user, err := a.users.Get(ctx, userID)
+	if fault.Is(err, fault.NotFound) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	p := Principal{UserID: userID, Roles: user.Roles, Scopes: token.Scopes, Method: MethodOpaque}
+	a.store(credential, p, token.ExpiresAt)
+	return p, nil
+}
+
+func (a *tokenAuthenticator) cached(credential string) (Principal, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, exist := a.cache[credential]
+	if !exist {
+		return Principal{}, false
+	}
+	if a.now().After(entry.expires) {
+		delete(a.cache, credential)
+		return Principal{}, false
+	}
+	return entry.principal, true
+}
+
+// store caches p until the cache TTL elapses or the token expires, whichever comes
+// first. Revoked tokens stay valid until their entry expires.
+func (a *tokenAuthenticator) store(credential string, p Principal, tokenExpiry *time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if len(a.cache) >= a.size {
+		for key, entry := range a.cache {
+			if now.After(entry.expires) {
+				delete(a.cache, key)
+			}
+		}
+	}
+	if len(a.cache) >= a.size {
+		// still full of live entries: drop an arbitrary one
+		for key := range a.cache {
+			delete(a.cache, key)
+			break
+		}
+	}
+	expires := now.Add(a.ttl)
+	if tokenExpiry != nil && tokenExpiry.Before(expires) {
+		expires = *tokenExpiry
+	}
+	a.cache[credential] = cacheEntry{principal: p, expires: expires}
+}
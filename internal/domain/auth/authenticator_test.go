@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type tokenFinderMock struct {
+	mock.Mock
+}
+
+func (tf *tokenFinderMock) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	args := tf.Called(ctx, value)
+	return args.Get(0).(model.Token), args.Error(1)
+}
+
+type userFinderMock struct {
+	mock.Mock
+}
+
+func (uf *userFinderMock) Get(ctx context.Context, id model.UserID) (*model.User, error) {
+	args := uf.Called(ctx, id)
+	res, _ := args.Get(0).(*model.User)
+	return res, args.Error(1)
+}
+
+func initTest() (context.Context, *tokenFinderMock, *tokenAuthenticator) {
+	finder, users := &tokenFinderMock{}, &userFinderMock{}
+	users.On("Get", mock.Anything, model.UserID(7)).Return(&model.User{Id: 7, Roles: []string{"admin"}}, nil)
+	users.On("Get", mock.Anything, model.UserID(1)).Return(&model.User{Id: 1}, nil)
+	users.On("Get", mock.Anything, model.UserID(2)).Return(nil, model.ErrUserNotFound)
+	a := NewAuthenticator(finder, users, Config{CacheTTLSeconds: 10, CacheSize: 2}).(*tokenAuthenticator)
+	return context.Background(), finder, a
+}
+
+func TestAuthenticator_Authenticate_Cached(t *testing.T) {
+	ctx, finder, a := initTest()
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	finder.On("FindByValue", ctx, "secret").Return(model.Token{Id: "secret", UserId: "7"}, nil).Twice()
+
+	p, err := a.Authenticate(ctx, "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, Principal{UserID: 7, Roles: []string{"admin"}, Method: MethodOpaque}, p)
+
+	p, err = a.Authenticate(ctx, "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, Principal{UserID: 7, Roles: []string{"admin"}, Method: MethodOpaque}, p)
+	finder.AssertNumberOfCalls(t, "FindByValue", 1)
+
+	now = now.Add(11 * time.Second)
+	_, err = a.Authenticate(ctx, "secret")
+	assert.Nil(t, err)
+	finder.AssertNumberOfCalls(t, "FindByValue", 2)
+}
+
+func TestAuthenticator_Authenticate_Invalid(t *testing.T) {
+	ctx, finder, a := initTest()
+	finder.On("FindByValue", ctx, "unknown").Return(model.Token{}, fault.New(fault.NotFound, "token_not_found"))
+	finder.On("FindByValue", ctx, "orphan").Return(model.Token{Id: "orphan", UserId: "abc"}, nil)
+	finder.On("FindByValue", ctx, "deleted").Return(model.Token{Id: "deleted", UserId: "2"}, nil)
+
+	for _, credential := range []string{"", "unknown", "orphan", "deleted"} {
+		_, err := a.Authenticate(ctx, credential)
+
+		assert.Equal(t, ErrInvalidCredentials, err, credential)
+	}
+}
+
+func TestAuthenticator_Authenticate_Lifecycle(t *testing.T) {
+	ctx, finder, a := initTest()
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	expiresAt, expired := now.Add(5*time.Second), now.Add(-time.Second)
+	finder.On("FindByValue", ctx, "scoped").Return(model.Token{Id: "scoped", UserId: "7", ExpiresAt: &expiresAt, Scopes: []string{"users:read"}, Status: model.TokenStatusActive}, nil)
+	finder.On("FindByValue", ctx, "revoked").Return(model.Token{Id: "revoked", UserId: "7", ExpiresAt: &expiresAt, Status: model.TokenStatusRevoked}, nil)
+	finder.On("FindByValue", ctx, "expired").Return(model.Token{Id: "expired", UserId: "7", ExpiresAt: &expired, Status: model.TokenStatusActive}, nil)
+
+	p, err := a.Authenticate(ctx, "scoped")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users:read"}, p.Scopes)
+	_, err = a.Authenticate(ctx, "revoked")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = a.Authenticate(ctx, "expired")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	// the cache entry doesn't outlive the token
+	now = now.Add(6 * time.Second)
+	_, err = a.Authenticate(ctx, "scoped")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	finder.AssertNumberOfCalls(t, "FindByValue", 4)
+}
+
+func TestTokenFinders(t *testing.T) {
+	ctx := context.Background()
+	issued, remote := &tokenFinderMock{}, &tokenFinderMock{}
+	notFound := fault.New(fault.NotFound, "token_not_found")
+	issued.On("FindByValue", ctx, "at_1").Return(model.Token{Id: "at_1", UserId: "1"}, nil)
+	issued.On("FindByValue", ctx, "legacy").Return(model.Token{}, notFound)
+	issued.On("FindByValue", ctx, "unknown").Return(model.Token{}, notFound)
+	remote.On("FindByValue", ctx, "legacy").Return(model.Token{Id: "legacy", UserId: "2"}, nil)
+	remote.On("FindByValue", ctx, "unknown").Return(model.Token{}, notFound)
+	finders := TokenFinders{issued, remote}
+
+	token, err := finders.FindByValue(ctx, "at_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", token.UserId)
+	token, err = finders.FindByValue(ctx, "legacy")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", token.UserId)
+	_, err = finders.FindByValue(ctx, "unknown")
+	assert.True(t, fault.Is(err, fault.NotFound))
+	remote.AssertNotCalled(t, "FindByValue", ctx, "at_1")
+}
+
+func TestAuthenticator_Authenticate_Unavailable(t *testing.T) {
+	ctx, finder, a := initTest()
+	finder.On("FindByValue", ctx, "secret").Return(model.Token{}, fault.New(fault.Unavailable, "token_api_unavailable"))
+
+	_, err := a.Authenticate(ctx, "secret")
+
+	assert.True(t, fault.Is(err, fault.Unavailable))
+}
+
+func TestAuthenticator_CacheSizeBounded(t *testing.T) {
+	ctx, finder, a := initTest()
+	for _, credential := range []string{"a", "b", "c"} {
+		finder.On("FindByValue", ctx, credential).Return(model.Token{Id: credential, UserId: "1"}, nil)
+		_, err := a.Authenticate(ctx, credential)
+		assert.Nil(t, err)
+	}
+
+	assert.Len(t, a.cache, 2)
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"github.com/api_base/tool/validation"
+	"time"
+)
+
+// default values
+const (
+	defaultCacheTTL  = 60 * time.Second
+	defaultCacheSize = 1000
+)
+
+// Config authentication config
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	CacheTTLSeconds time.Duration `yaml:"cache_ttl_seconds"`
+	CacheSize       int           `yaml:"cache_size"`
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if c.CacheTTLSeconds < 0 {
+		problems.Addf("cache_ttl_seconds", "must not be negative")
+	}
+	if c.CacheSize < 0 {
+		problems.Addf("cache_size", "must not be negative")
+	}
+	return problems.Err()
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/validation"
+	"sort"
+	"strings"
+)
+
+// Permission is an action over a kind of resource, e.g. "users:read".
+type Permission string
+
+const (
+	UsersRead       Permission = "users:read"
+	UsersWrite      Permission = "users:write"
+	TokensWrite     Permission = "tokens:write"
+	OnboardingWrite Permission = "onboarding:write"
+
+	// selfScope restricts a granted permission to the resources owned by the principal,
+	// e.g. "users:read:self".
+	selfScope = ":self"
+)
+
+// PolicyConfig maps every role to the permissions it grants. Principals without
+// roles get DefaultRoles.
+type PolicyConfig struct {
+	Roles        map[string][]string `yaml:"roles"`
+	DefaultRoles []string            `yaml:"default_roles"`
+}
+
+var knownPermissions = []Permission{UsersRead, UsersWrite, TokensWrite, OnboardingWrite}
+
+// Validate reports unknown permissions and default roles without a definition.
+func (c PolicyConfig) Validate() error {
+	problems := validation.Problems{}
+	roles := make([]string, 0, len(c.Roles))
+	for role := range c.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		for i, raw := range c.Roles[role] {
+			if !KnownPermission(Permission(strings.TrimSuffix(raw, selfScope))) {
+				problems.Addf(fmt.Sprintf("roles.%s[%d]", role, i), "unknown permission %q", raw)
+			}
+		}
+	}
+	for i, role := range c.DefaultRoles {
+		if _, exist := c.Roles[role]; !exist {
+			problems.Addf(fmt.Sprintf("default_roles[%d]", i), "role %q is not defined in roles", role)
+		}
+	}
+	return problems.Err()
+}
+
+// KnownPermission reports whether perm is one of the permissions of the api.
+func KnownPermission(perm Permission) bool {
+	for _, known := range knownPermissions {
+		if perm == known {
+			return true
+		}
+	}
+	return false
+}
+
+type grant struct {
+	any  bool
+	self bool
+}
+
+// Policy decides which permissions a principal holds.
+type Policy struct {
+	grants       map[string]map[Permission]grant
+	defaultRoles []string
+}
+
+func NewPolicy(config PolicyConfig) *Policy {
+	grants := make(map[string]map[Permission]grant, len(config.Roles))
+	for role, permissions := range config.Roles {
+		grants[role] = map[Permission]grant{}
+		for _, raw := range permissions {
+			perm, self := Permission(raw), false
+			if strings.HasSuffix(raw, selfScope) {
+				perm, self = Permission(strings.TrimSuffix(raw, selfScope)), true
+			}
+			g := grants[role][perm]
+			if self {
+				g.self = true
+			} else {
+				g.any = true
+			}
+			grants[role][perm] = g
+		}
+	}
+	return &Policy{
+		grants:       grants,
+		defaultRoles: config.DefaultRoles,
+	}
+}
+
+// Allowed reports whether p holds perm over a resource owned by owner. A zero owner
+// means the resource is not owned by a single user, so only unrestricted grants apply.
+// The scopes of p, when restricted, must include perm. A nil Policy allows nothing.
+func (pol *Policy) Allowed(p Principal, perm Permission, owner model.UserID) bool {
+	if pol == nil {
+		return false
+	}
+	if p.Scopes != nil && !inScopes(p.Scopes, perm) {
+		return false
+	}
+	roles := p.Roles
+	if len(roles) == 0 {
+		roles = pol.defaultRoles
+	}
+	for _, role := range roles {
+		g := pol.grants[role][perm]
+		if g.any || (g.self && owner.Valid() && owner == p.UserID) {
+			return true
+		}
+	}
+	return false
+}
+
+func inScopes(scopes []string, perm Permission) bool {
+	for _, scope := range scopes {
+		if Permission(scope) == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"github.com/api_base/internal/domain/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPolicy_Allowed(t *testing.T) {
+	pol := NewPolicy(PolicyConfig{
+		Roles: map[string][]string{
+			"admin": {"users:read", "users:write"},
+			"user":  {"users:read:self"},
+		},
+		DefaultRoles: []string{"user"},
+	})
+	admin := Principal{UserID: 1, Roles: []string{"admin"}}
+	user := Principal{UserID: 2, Roles: []string{"user"}}
+	noRoles := Principal{UserID: 3}
+	unknownRole := Principal{UserID: 4, Roles: []string{"guest"}}
+	scopedAdmin := Principal{UserID: 1, Roles: []string{"admin"}, Scopes: []string{"users:read"}}
+
+	tests := []struct {
+		p       Principal
+		perm    Permission
+		owner   model.UserID
+		allowed bool
+	}{
+		{admin, UsersRead, 2, true},
+		{admin, UsersRead, 0, true},
+		{admin, UsersWrite, 2, true},
+		{user, UsersRead, 2, true},
+		{user, UsersRead, 1, false},
+		{user, UsersRead, 0, false},
+		{user, UsersWrite, 2, false},
+		{noRoles, UsersRead, 3, true},
+		{noRoles, UsersRead, 2, false},
+		{unknownRole, UsersRead, 4, false},
+		{scopedAdmin, UsersRead, 2, true},
+		{scopedAdmin, UsersWrite, 2, false},
+	}
+	for i, tt := range tests {
+		assert.Equal(t, tt.allowed, pol.Allowed(tt.p, tt.perm, tt.owner), i)
+	}
+
+	var none *Policy
+	assert.False(t, none.Allowed(Principal{UserID: 1, Roles: []string{"admin"}}, UsersRead, 1), "a nil policy allows nothing")
+}
+
+func TestPolicyConfig_Validate(t *testing.T) {
+	valid := PolicyConfig{
+		Roles:        map[string][]string{"admin": {"users:read", "users:write"}, "user": {"users:read:self"}},
+		DefaultRoles: []string{"user"},
+	}
+	assert.Nil(t, valid.Validate())
+
+	invalid := PolicyConfig{
+		Roles:        map[string][]string{"user": {"users:read:self", "user:read"}},
+		DefaultRoles: []string{"guest"},
+	}
+	assert.EqualError(t, invalid.Validate(), `2 config problem(s): roles.user[1]: unknown permission "user:read"; default_roles[0]: role "guest" is not defined in roles`)
+}
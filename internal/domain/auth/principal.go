@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/model"
+)
+
+// Methods authenticating a principal.
+const (
+	MethodOpaque = "opaque"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID model.UserID
+	Roles  []string
+	// Scopes restricts the permissions of the roles to the listed ones, nil means
+	// the credential is not restricted.
+	Scopes []string
+	// Method is how the credential was verified, MethodOpaque or MethodJWT.
+	Method string
+}
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx by NewContext.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
@@ -2,39 +2,466 @@ package domain
 
 import (
 	"context"
+	"fmt"
 	"github.com/api_base/config"
 	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository"
+	"github.com/api_base/internal/repository/memory"
+	"github.com/api_base/internal/repository/onboarding"
+	"github.com/api_base/internal/repository/outbox"
 	"github.com/api_base/internal/repository/token"
 	"github.com/api_base/internal/repository/user"
+	"github.com/api_base/tool/container"
 	"github.com/api_base/tool/database"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/metrics"
 	"github.com/api_base/tool/restclient"
-	"log"
 )
 
+// Names of the components registered by NewContainer, Register replaces them.
+const (
+	DatabaseComponent   = "database"
+	RestClientComponent = "rest_client"
+	UserRepoComponent   = "user_repository"
+	TokenRepoComponent  = "token_repository"
+	FixtureComponent    = "fixture"
+	// LocalTokenRepoComponent and RemoteTokenRepoComponent are the token sources
+	// TokenRepoComponent reads from, depending on repository.tokens.mode.
+	LocalTokenRepoComponent  = "local_token_repository"
+	RemoteTokenRepoComponent = "remote_token_repository"
+	TokenSyncComponent       = "token_synchronizer"
+	TokenStoreComponent      = "token_store"
+	OnboardingStoreComponent = "onboarding_store"
+	// OutboxComponent holds the events recorded by the repositories, OutboxRelayComponent
+	// publishes them to the sinks of the outbox config once resolved.
+	OutboxComponent      = "outbox"
+	OutboxRelayComponent = "outbox_relay"
+)
+
+// Container builds the dependencies of the services on first use. Nothing connects
+// until a component needing it is resolved.
 type Container struct {
-	UserRepo  UserRepository
-	TokenRepo TokenRepository
+	*container.Container
+	// Config holds the current config, it changes when the config is reloaded.
+	Config *config.Store
+	Logger *logger.Logger
 }
 
 type UserRepository interface {
-	Get(ctx context.Context, id int64) (*model.User, error)
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+	GetMany(ctx context.Context, ids []model.UserID) ([]model.User, error)
+	Create(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (model.User, error)
+	Update(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) error
 }
 
 type TokenRepository interface {
-	Get(ctx context.Context, id string) (model.Token, error)
+	Get(ctx context.Context, id model.UserID) (model.Token, error)
+	FindByValue(ctx context.Context, value string) (model.Token, error)
+}
+
+// TokenStore persists the tokens issued by the api, their audit trail and the events
+// of the changes.
+type TokenStore interface {
+	Save(ctx context.Context, records []model.TokenRecord, audit []model.TokenAudit, events []model.Event) error
+	Get(ctx context.Context, id string) (model.TokenRecord, error)
+	FindByHash(ctx context.Context, hash string) (model.TokenRecord, error)
+	ListByUser(ctx context.Context, userID model.UserID) ([]model.TokenRecord, error)
+}
+
+// OnboardingStore persists the onboarding step of the users and their transitions.
+type OnboardingStore interface {
+	Get(ctx context.Context, userID model.UserID) (model.Onboarding, error)
+	Save(ctx context.Context, onboarding model.Onboarding, transition model.OnboardingTransition, events []model.Event) error
+}
+
+func NewContainer(store *config.Store, lg *logger.Logger) *Container {
+	c := &Container{
+		Container: container.NewContainer(),
+		Config:    store,
+		Logger:    lg,
+	}
+	_ = c.Register(DatabaseComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := database.NewRepository(store.Load().Database)
+		if err != nil {
+			return nil, err
+		}
+		database.RegisterMetrics(metrics.DefaultRegistry, db)
+		r.OnStop(func(context.Context) error { return db.Close() })
+		return db, nil
+	})
+	_ = c.Register(RestClientComponent, func(r container.Resolver) (interface{}, error) {
+		rc, err := restclient.NewRestClient(store.Load().RestClient)
+		if err != nil {
+			return nil, err
+		}
+		r.OnStop(func(context.Context) error {
+			rc.CloseIdleConnections()
+			return nil
+		})
+		return rc, nil
+	})
+	_ = c.Register(UserRepoComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := resolveDatabase(r)
+		if err != nil {
+			return nil, err
+		}
+		return user.NewRepository(db), nil
+	})
+	_ = c.Register(RemoteTokenRepoComponent, func(r container.Resolver) (interface{}, error) {
+		rc, err := resolveRestClient(r)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewRepository(rc), nil
+	})
+	_ = c.Register(LocalTokenRepoComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := resolveDatabase(r)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewLocalRepository(db), nil
+	})
+	_ = c.Register(TokenRepoComponent, func(r container.Resolver) (interface{}, error) {
+		conf := store.Load().Repository.Tokens
+		remote, err := resolveRemoteTokens(r)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Mode == "" || conf.Mode == repository.ModeRemoteOnly {
+			return remote, nil
+		}
+		local, err := resolveLocalTokens(r)
+		if err != nil {
+			return nil, err
+		}
+		if conf.SyncInterval() > 0 {
+			if _, err := r.Resolve(TokenSyncComponent); err != nil {
+				return nil, err
+			}
+		}
+		return token.NewModeRepository(conf.Mode, local, remote), nil
+	})
+	_ = c.Register(TokenSyncComponent, func(r container.Resolver) (interface{}, error) {
+		local, err := resolveLocalTokens(r)
+		if err != nil {
+			return nil, err
+		}
+		remote, err := resolveRemoteTokens(r)
+		if err != nil {
+			return nil, err
+		}
+		synchronizer := token.NewSynchronizer(local, remote, lg)
+		interval := store.Load().Repository.Tokens.SyncInterval()
+		var cancel context.CancelFunc
+		done := make(chan struct{})
+		r.OnStart(func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				synchronizer.Run(ctx, interval)
+			}()
+			return nil
+		})
+		r.OnStop(func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		return synchronizer, nil
+	})
+	_ = c.Register(TokenStoreComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := resolveDatabase(r)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewLifecycleStore(db), nil
+	})
+	_ = c.Register(OnboardingStoreComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := resolveDatabase(r)
+		if err != nil {
+			return nil, err
+		}
+		return onboarding.NewRepository(db), nil
+	})
+	_ = c.Register(OutboxComponent, func(r container.Resolver) (interface{}, error) {
+		db, err := resolveDatabase(r)
+		if err != nil {
+			return nil, err
+		}
+		return outbox.NewRepository(db), nil
+	})
+	_ = c.Register(OutboxRelayComponent, func(r container.Resolver) (interface{}, error) {
+		component, err := r.Resolve(OutboxComponent)
+		if err != nil {
+			return nil, err
+		}
+		events, ok := component.(outbox.Store)
+		if !ok {
+			return nil, typeError(OutboxComponent, component)
+		}
+		conf := store.Load().Outbox
+		sinks, err := newSinks(r, conf.Sinks)
+		if err != nil {
+			return nil, err
+		}
+		relay := outbox.NewRelay(events, sinks, conf.MaxAttempts, lg)
+		var cancel context.CancelFunc
+		done := make(chan struct{})
+		r.OnStart(func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				relay.Run(ctx, conf.Interval(), conf.BatchSize)
+			}()
+			return nil
+		})
+		r.OnStop(func(ctx context.Context) error {
+			if cancel == nil {
+				return nil
+			}
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		return relay, nil
+	})
+	if conf := store.Load().Repository; conf.Memory() {
+		registerMemory(c, conf.Fixture)
+	}
+	return c
+}
+
+// registerMemory replaces the repositories with memory ones seeded from the fixture
+// file, when set.
+func registerMemory(c *Container, fixture string) {
+	_ = c.Register(FixtureComponent, func(container.Resolver) (interface{}, error) {
+		if fixture == "" {
+			return memory.Fixture{}, nil
+		}
+		return memory.LoadFixture(fixture)
+	})
+	_ = c.Register(OutboxComponent, func(container.Resolver) (interface{}, error) {
+		return memory.NewOutbox(), nil
+	})
+	_ = c.Register(UserRepoComponent, func(r container.Resolver) (interface{}, error) {
+		f, err := resolveFixture(r)
+		if err != nil {
+			return nil, err
+		}
+		events, err := resolveMemoryOutbox(r)
+		if err != nil {
+			return nil, err
+		}
+		return memory.NewUserRepository(events, f.UserModels()...), nil
+	})
+	_ = c.Register(TokenRepoComponent, func(r container.Resolver) (interface{}, error) {
+		f, err := resolveFixture(r)
+		if err != nil {
+			return nil, err
+		}
+		return memory.NewTokenRepository(f.TokenModels()...), nil
+	})
+	_ = c.Register(TokenStoreComponent, func(r container.Resolver) (interface{}, error) {
+		events, err := resolveMemoryOutbox(r)
+		if err != nil {
+			return nil, err
+		}
+		return memory.NewTokenStore(events), nil
+	})
+	_ = c.Register(OnboardingStoreComponent, func(r container.Resolver) (interface{}, error) {
+		events, err := resolveMemoryOutbox(r)
+		if err != nil {
+			return nil, err
+		}
+		return memory.NewOnboardingStore(events), nil
+	})
+}
+
+func (c *Container) Database() (database.Database, error) {
+	return resolveDatabase(c)
+}
+
+func (c *Container) RestClient() (restclient.RestClient, error) {
+	return resolveRestClient(c)
+}
+
+func (c *Container) UserRepository() (UserRepository, error) {
+	component, err := c.Resolve(UserRepoComponent)
+	if err != nil {
+		return nil, err
+	}
+	repo, ok := component.(UserRepository)
+	if !ok {
+		return nil, typeError(UserRepoComponent, component)
+	}
+	return repo, nil
+}
+
+func (c *Container) TokenRepository() (TokenRepository, error) {
+	component, err := c.Resolve(TokenRepoComponent)
+	if err != nil {
+		return nil, err
+	}
+	repo, ok := component.(TokenRepository)
+	if !ok {
+		return nil, typeError(TokenRepoComponent, component)
+	}
+	return repo, nil
+}
+
+func (c *Container) TokenStore() (TokenStore, error) {
+	component, err := c.Resolve(TokenStoreComponent)
+	if err != nil {
+		return nil, err
+	}
+	store, ok := component.(TokenStore)
+	if !ok {
+		return nil, typeError(TokenStoreComponent, component)
+	}
+	return store, nil
+}
+
+func (c *Container) OnboardingStore() (OnboardingStore, error) {
+	component, err := c.Resolve(OnboardingStoreComponent)
+	if err != nil {
+		return nil, err
+	}
+	store, ok := component.(OnboardingStore)
+	if !ok {
+		return nil, typeError(OnboardingStoreComponent, component)
+	}
+	return store, nil
+}
+
+// Reconfigure applies a reloaded config to the http clients and the database pool,
+// which is reopened when its connection settings change. Components not built yet
+// read the config when they are.
+func (c *Container) Reconfigure(old, current config.Config) error {
+	if component, ok := c.Lookup(RestClientComponent); ok {
+		if rc, ok := component.(restclient.RestClient); ok {
+			rc.Reconfigure(current.RestClient)
+		}
+	}
+	if component, ok := c.Lookup(DatabaseComponent); ok {
+		if db, ok := component.(database.Database); ok {
+			return db.Reconfigure(current.Database)
+		}
+	}
+	return nil
+}
+
+func resolveDatabase(r container.Resolver) (database.Database, error) {
+	component, err := r.Resolve(DatabaseComponent)
+	if err != nil {
+		return nil, err
+	}
+	db, ok := component.(database.Database)
+	if !ok {
+		return nil, typeError(DatabaseComponent, component)
+	}
+	return db, nil
+}
+
+func resolveRestClient(r container.Resolver) (restclient.RestClient, error) {
+	component, err := r.Resolve(RestClientComponent)
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := component.(restclient.RestClient)
+	if !ok {
+		return nil, typeError(RestClientComponent, component)
+	}
+	return rc, nil
+}
+
+func resolveRemoteTokens(r container.Resolver) (*token.Repository, error) {
+	component, err := r.Resolve(RemoteTokenRepoComponent)
+	if err != nil {
+		return nil, err
+	}
+	repo, ok := component.(*token.Repository)
+	if !ok {
+		return nil, typeError(RemoteTokenRepoComponent, component)
+	}
+	return repo, nil
 }
 
-func NewContainer(config config.Config) Container {
-	db, err := database.NewRepository(config.Database)
+func resolveLocalTokens(r container.Resolver) (*token.LocalRepository, error) {
+	component, err := r.Resolve(LocalTokenRepoComponent)
 	if err != nil {
-		log.Fatal("initialize database fail: ", err)
+		return nil, err
+	}
+	repo, ok := component.(*token.LocalRepository)
+	if !ok {
+		return nil, typeError(LocalTokenRepoComponent, component)
 	}
-	rc, err := restclient.NewRestClient(config.RestClient)
+	return repo, nil
+}
+
+func resolveFixture(r container.Resolver) (memory.Fixture, error) {
+	component, err := r.Resolve(FixtureComponent)
 	if err != nil {
-		log.Fatal("initialize rest_client fail: ", err)
+		return memory.Fixture{}, err
 	}
-	return Container{
-		UserRepo:  user.NewRepository(db),
-		TokenRepo: token.NewRepository(rc),
+	f, ok := component.(memory.Fixture)
+	if !ok {
+		return memory.Fixture{}, typeError(FixtureComponent, component)
 	}
+	return f, nil
+}
+
+func resolveMemoryOutbox(r container.Resolver) (*memory.Outbox, error) {
+	component, err := r.Resolve(OutboxComponent)
+	if err != nil {
+		return nil, err
+	}
+	events, ok := component.(*memory.Outbox)
+	if !ok {
+		return nil, typeError(OutboxComponent, component)
+	}
+	return events, nil
+}
+
+// newSinks builds the sinks of the relay, files are closed on stop.
+func newSinks(r container.Resolver, configs []outbox.SinkConfig) ([]outbox.Sink, error) {
+	sinks := make([]outbox.Sink, 0, len(configs))
+	for _, conf := range configs {
+		switch conf.Type {
+		case outbox.SinkStdout:
+			sinks = append(sinks, outbox.NewStdoutSink())
+		case outbox.SinkFile:
+			sink, err := outbox.NewFileSink(conf.Path)
+			if err != nil {
+				return nil, err
+			}
+			r.OnStop(func(context.Context) error { return sink.Close() })
+			sinks = append(sinks, sink)
+		case outbox.SinkWebhook:
+			rc, err := resolveRestClient(r)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, outbox.NewWebhookSink(rc))
+		default:
+			return nil, fmt.Errorf("unknown sink %q", conf.Type)
+		}
+	}
+	return sinks, nil
+}
+
+func typeError(name string, component interface{}) error {
+	return fmt.Errorf("component %s has unexpected type %T", name, component)
 }
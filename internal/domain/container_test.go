@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"github.com/api_base/config"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository"
+	"github.com/api_base/internal/repository/token"
+	"github.com/api_base/tool/container"
+	"github.com/api_base/tool/logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeUserRepository struct {
+	UserRepository
+}
+
+func TestContainer_RegisterReplacesComponent(t *testing.T) {
+	c := NewContainer(config.NewStore(config.Config{}), logger.Discard())
+	fake := fakeUserRepository{}
+	err := c.Register(UserRepoComponent, func(container.Resolver) (interface{}, error) {
+		return fake, nil
+	})
+
+	repo, resolveErr := c.UserRepository()
+
+	assert.Nil(t, err)
+	assert.Nil(t, resolveErr)
+	assert.Equal(t, fake, repo)
+	_, built := c.Lookup(DatabaseComponent)
+	assert.False(t, built)
+}
+
+func TestContainer_TypeError(t *testing.T) {
+	c := NewContainer(config.NewStore(config.Config{}), logger.Discard())
+	_ = c.Register(TokenRepoComponent, func(container.Resolver) (interface{}, error) {
+		return model.Token{}, nil
+	})
+
+	repo, err := c.TokenRepository()
+
+	assert.Nil(t, repo)
+	assert.EqualError(t, err, "component token_repository has unexpected type model.Token")
+}
+
+func TestContainer_ReconfigureBeforeBuild(t *testing.T) {
+	c := NewContainer(config.NewStore(config.Config{}), logger.Discard())
+
+	assert.Nil(t, c.Reconfigure(config.Config{}, config.Config{}))
+	assert.Nil(t, c.Stop(context.Background()))
+}
+
+func TestContainer_MemoryRepositories(t *testing.T) {
+	store := config.NewStore(config.Config{Repository: repository.Config{
+		Driver:  repository.DriverMemory,
+		Fixture: "../repository/memory/testdata/fixture.yml",
+	}})
+	c := NewContainer(store, logger.Discard())
+
+	users, err := c.UserRepository()
+	assert.Nil(t, err)
+	tokens, err := c.TokenRepository()
+	assert.Nil(t, err)
+
+	user, err := users.Get(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", user.Name)
+	token, err := tokens.FindByValue(context.Background(), "token_2")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", token.UserId)
+	_, err = c.TokenStore()
+	assert.Nil(t, err)
+	_, err = c.Resolve(OutboxRelayComponent)
+	assert.Nil(t, err)
+	_, built := c.Lookup(DatabaseComponent)
+	assert.False(t, built)
+}
+
+func TestContainer_TokenModes(t *testing.T) {
+	conf := config.Config{}
+	conf.RestClient.TimeoutMillis = 1000
+	c := NewContainer(config.NewStore(conf), logger.Discard())
+	tokens, err := c.TokenRepository()
+	assert.Nil(t, err)
+	assert.IsType(t, &token.Repository{}, tokens)
+
+	conf.Repository.Tokens = repository.TokensConfig{Mode: repository.ModeLocalFirst}
+	c = NewContainer(config.NewStore(conf), logger.Discard())
+	_ = c.Register(LocalTokenRepoComponent, func(container.Resolver) (interface{}, error) {
+		return token.NewLocalRepository(nil), nil
+	})
+	tokens, err = c.TokenRepository()
+	assert.Nil(t, err)
+	_, remoteOnly := tokens.(*token.Repository)
+	assert.False(t, remoteOnly)
+	_, synchronized := c.Lookup(TokenSyncComponent)
+	assert.False(t, synchronized)
+}
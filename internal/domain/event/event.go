@@ -0,0 +1,88 @@
+package event
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/api_base/internal/domain/model"
+	"time"
+)
+
+const idBytes = 16
+
+// UserPayload is the payload of the user.created and user.updated events, the user
+// after the change.
+type UserPayload struct {
+	ID    model.UserID `json:"id"`
+	Name  string       `json:"name"`
+	Roles []string     `json:"roles"`
+	Actor model.UserID `json:"actor,omitempty"`
+}
+
+// TokenRevokedPayload is the payload of the token.revoked event. Reason is the audit
+// event revoking the token, revoked or rotated.
+type TokenRevokedPayload struct {
+	TokenID string           `json:"token_id"`
+	UserID  model.UserID     `json:"user_id"`
+	Reason  model.TokenEvent `json:"reason"`
+	Actor   model.UserID     `json:"actor,omitempty"`
+}
+
+// OnboardingAdvancedPayload is the payload of the onboarding.advanced event.
+// Completed tells whether To is the last step.
+type OnboardingAdvancedPayload struct {
+	UserID    model.UserID `json:"user_id"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Completed bool         `json:"completed"`
+	Actor     model.UserID `json:"actor,omitempty"`
+}
+
+// New returns an event of typ about aggregateID with a new random ID.
+func New(typ model.EventType, aggregateID string, payload interface{}, at time.Time) (model.Event, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return model.Event{}, err
+	}
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return model.Event{}, err
+	}
+	return model.Event{
+		ID:          hex.EncodeToString(b),
+		Type:        typ,
+		AggregateID: aggregateID,
+		OccurredAt:  at.UTC(),
+		Payload:     raw,
+	}, nil
+}
+
+// User returns the event of typ about user.
+func User(typ model.EventType, user model.User, actor model.UserID, at time.Time) (model.Event, error) {
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return New(typ, user.Id.String(), UserPayload{ID: user.Id, Name: user.Name, Roles: roles, Actor: actor}, at)
+}
+
+// TokenRevoked returns the token.revoked event of the revocation recorded by audit.
+func TokenRevoked(audit model.TokenAudit) (model.Event, error) {
+	return New(model.EventTokenRevoked, audit.TokenID, TokenRevokedPayload{
+		TokenID: audit.TokenID,
+		UserID:  audit.UserID,
+		Reason:  audit.Event,
+		Actor:   audit.Actor,
+	}, audit.At)
+}
+
+// OnboardingAdvanced returns the onboarding.advanced event of transition of the user.
+func OnboardingAdvanced(userID model.UserID, transition model.OnboardingTransition, completed bool) (model.Event, error) {
+	return New(model.EventOnboardingAdvanced, userID.String(), OnboardingAdvancedPayload{
+		UserID:    userID,
+		From:      transition.From,
+		To:        transition.To,
+		Completed: completed,
+		Actor:     transition.Actor,
+	}, transition.At)
+}
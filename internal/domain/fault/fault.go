@@ -0,0 +1,99 @@
+package fault
+
+import "errors"
+
+// Kind classifies a domain error independently of the transport used to report it.
+type Kind int
+
+const (
+	Internal Kind = iota
+	NotFound
+	InvalidArgument
+	Conflict
+	Unavailable
+	Unauthorized
+	Forbidden
+)
+
+var kindNames = map[Kind]string{
+	Internal:        "internal",
+	NotFound:        "not_found",
+	InvalidArgument: "invalid_argument",
+	Conflict:        "conflict",
+	Unavailable:     "unavailable",
+	Unauthorized:    "unauthorized",
+	Forbidden:       "forbidden",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Error is the error returned by repositories and services of the domain.
+// Message is safe to show to API consumers, Err keeps the underlying cause.
+type Error struct {
+	Kind       Kind
+	Message    string
+	Err        error
+	Violations []Violation
+}
+
+// Violation tells which rule a single field of an input broke.
+type Violation struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	if e.Message == "" {
+		return e.Kind.String()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates a domain error of the given kind.
+func New(kind Kind, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap creates a domain error of the given kind keeping err as its cause.
+func Wrap(kind Kind, message string, err error) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// Invalid creates an InvalidArgument error detailing every violated rule.
+func Invalid(message string, violations ...Violation) error {
+	return &Error{Kind: InvalidArgument, Message: message, Violations: violations}
+}
+
+// KindOf returns the kind of the first domain error found in the chain of err.
+// Errors outside of the taxonomy are considered Internal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
+
+// ViolationsOf returns the violations carried by the first domain error found in the chain of err.
+func ViolationsOf(err error) []Violation {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Violations
+	}
+	return nil
+}
+
+// Is reports whether err is a domain error of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
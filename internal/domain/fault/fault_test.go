@@ -0,0 +1,28 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("get user: %w", Wrap(Unavailable, "database_unavailable", cause))
+
+	assert.Equal(t, Unavailable, KindOf(err))
+	assert.True(t, Is(err, Unavailable))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, "get user: database_unavailable", err.Error())
+}
+
+func TestKindOf_Internal(t *testing.T) {
+	assert.Equal(t, Internal, KindOf(errors.New("boom")))
+	assert.False(t, Is(nil, Internal))
+}
+
+func TestError_Message(t *testing.T) {
+	assert.Equal(t, "not_found", New(NotFound, "").Error())
+	assert.Equal(t, "boom", Wrap(Conflict, "", errors.New("boom")).Error())
+}
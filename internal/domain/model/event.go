@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType names a change of the domain.
+type EventType string
+
+const (
+	EventUserCreated  EventType = "user.created"
+	EventUserUpdated  EventType = "user.updated"
+	EventTokenRevoked EventType = "token.revoked"
+
+	EventOnboardingAdvanced EventType = "onboarding.advanced"
+)
+
+// Event is a change of the domain, recorded in the outbox in the transaction of the
+// change and delivered at least once to the sinks. ID is its idempotency key: a
+// redelivered event keeps its ID.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        EventType       `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
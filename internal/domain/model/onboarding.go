@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Onboarding is the progress of a user through the onboarding steps. Version
+// increases with every transition, guarding concurrent changes.
+type Onboarding struct {
+	UserID    UserID                 `json:"user_id"`
+	Step      string                 `json:"step"`
+	NextStep  string                 `json:"next_step,omitempty"`
+	Completed bool                   `json:"completed"`
+	Version   int                    `json:"-"`
+	UpdatedAt *time.Time             `json:"updated_at,omitempty"`
+	History   []OnboardingTransition `json:"history"`
+}
+
+// OnboardingTransition is a change of step. Actor is the user performing it, zero
+// when the request is not authenticated.
+type OnboardingTransition struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Actor UserID    `json:"actor,omitempty"`
+	At    time.Time `json:"at"`
+}
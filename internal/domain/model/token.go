@@ -1,6 +1,89 @@
 package model
 
+import "time"
+
+// TokenStatus is the state of a token in its lifecycle.
+type TokenStatus string
+
+const (
+	TokenStatusActive  TokenStatus = "active"
+	TokenStatusRevoked TokenStatus = "revoked"
+)
+
 type Token struct {
-	Id     string `json:"token"`
-	UserId string `json:"user_id" `
+	Id        string      `json:"token"`
+	UserId    string      `json:"user_id" `
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+	Scopes    []string    `json:"scopes,omitempty"`
+	Status    TokenStatus `json:"status,omitempty"`
+}
+
+// Active reports whether the token is usable at now. Tokens without status nor
+// expiry, such as the ones of token_api, are always active.
+func (t Token) Active(now time.Time) bool {
+	if t.Status != "" && t.Status != TokenStatusActive {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// TokenRecord is a token issued by the api as stored: its value is only known by
+// its holder, the record keeps a hash of it.
+type TokenRecord struct {
+	ID        string
+	UserID    UserID
+	Hash      string
+	Scopes    []string
+	Status    TokenStatus
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// Token returns the token of the record whose value is value.
+func (r TokenRecord) Token(value string) Token {
+	expiresAt := r.ExpiresAt
+	return Token{
+		Id:        value,
+		UserId:    r.UserID.String(),
+		ExpiresAt: &expiresAt,
+		Scopes:    r.Scopes,
+		Status:    r.Status,
+	}
+}
+
+// IssuedToken is returned once, when a token is issued: the value can't be read back.
+type IssuedToken struct {
+	ID        string      `json:"id"`
+	Token     string      `json:"token"`
+	UserID    UserID      `json:"user_id"`
+	Scopes    []string    `json:"scopes"`
+	Status    TokenStatus `json:"status"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// TokenEvent is a lifecycle event recorded in the audit trail of tokens.
+type TokenEvent string
+
+const (
+	TokenEventIssued  TokenEvent = "issued"
+	TokenEventRevoked TokenEvent = "revoked"
+	TokenEventRotated TokenEvent = "rotated"
+)
+
+// TokenAudit is an entry of the audit trail of tokens. Actor is the user performing
+// the change, zero when the request is not authenticated.
+type TokenAudit struct {
+	TokenID string
+	UserID  UserID
+	Event   TokenEvent
+	Actor   UserID
+	At      time.Time
+}
+
+// AccessToken is a signed, self-contained credential issued for a user.
+type AccessToken struct {
+	Token     string `json:"access_token"`
+	Type      string `json:"token_type"`
+	ExpiresIn int64  `json:"expires_in"`
 }
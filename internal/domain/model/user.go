@@ -1,7 +1,20 @@
 package model
 
+import "github.com/api_base/internal/domain/fault"
+
+var ErrUserNotFound = fault.New(fault.NotFound, "user_not_found")
+
 type User struct {
-	Id    int64  `json:"id"`
-	Name  string `json:"name"`
-	Token Token  `json:"token"`
+	Id    UserID   `json:"id"`
+	Name  string   `json:"name"`
+	Token Token    `json:"token"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// UserResult is the outcome of resolving a single id inside a batch lookup.
+// Either User or Err is set.
+type UserResult struct {
+	Id   UserID
+	User *User
+	Err  error
 }
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"github.com/api_base/internal/domain/fault"
+	"strconv"
+)
+
+var ErrInvalidUserID = fault.New(fault.InvalidArgument, "invalid_user_id")
+
+// UserID identifies a user across the handler, the service and every repository.
+type UserID int64
+
+// ParseUserID parses the canonical decimal representation of a user id.
+// Only plain digits are accepted: signs, spaces, leading zeros and values
+// outside of the positive int64 range are rejected.
+func ParseUserID(s string) (UserID, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, ErrInvalidUserID
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, ErrInvalidUserID
+		}
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidUserID
+	}
+	userID := UserID(id)
+	if !userID.Valid() {
+		return 0, ErrInvalidUserID
+	}
+	return userID, nil
+}
+
+// Valid reports whether the id is in the range of ids assigned by the database.
+func (id UserID) Valid() bool {
+	return id > 0
+}
+
+// Int64 returns the id as the value stored in the database.
+func (id UserID) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the decimal representation of the id.
+func (id UserID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseUserID(t *testing.T) {
+	id, err := ParseUserID("42")
+
+	assert.Nil(t, err)
+	assert.Equal(t, UserID(42), id)
+	assert.Equal(t, "42", id.String())
+	assert.Equal(t, int64(42), id.Int64())
+}
+
+func TestParseUserID_Invalid(t *testing.T) {
+	for _, raw := range []string{"", "abc", "4a", "-1", "+1", " 1", "0", "007", "1.5", "9223372036854775808"} {
+		id, err := ParseUserID(raw)
+
+		assert.Equal(t, ErrInvalidUserID, err, raw)
+		assert.Equal(t, UserID(0), id, raw)
+	}
+}
@@ -0,0 +1,81 @@
+package onboarding
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+)
+
+// DefaultSteps are the steps used when the config lists none.
+var DefaultSteps = []string{"created", "profile_completed", "token_issued", "verified", "active"}
+
+// DefaultAdminSteps are the admin steps of DefaultSteps.
+var DefaultAdminSteps = []string{"verified", "active"}
+
+// Config lists the onboarding steps in order: users start at the first one and
+// move to the next until the last one. Moving to one of AdminSteps requires
+// onboarding:write over every user, onboarding:write:self is not enough.
+type Config struct {
+	Steps      []string `yaml:"steps"`
+	AdminSteps []string `yaml:"admin_steps"`
+}
+
+func (c Config) steps() []string {
+	if len(c.Steps) == 0 {
+		return DefaultSteps
+	}
+	return c.Steps
+}
+
+func (c Config) adminSteps() []string {
+	if len(c.Steps) == 0 && c.AdminSteps == nil {
+		return DefaultAdminSteps
+	}
+	return c.AdminSteps
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if len(c.Steps) == 1 {
+		problems.Addf("steps", "must list at least 2 steps")
+	}
+	seen := map[string]bool{}
+	for i, step := range c.Steps {
+		path := fmt.Sprintf("steps[%d]", i)
+		if !validStep(step) {
+			problems.Addf(path, "%q must be lowercase letters, digits and underscores", step)
+		}
+		if seen[step] {
+			problems.Addf(path, "duplicated step %q", step)
+		}
+		seen[step] = true
+	}
+	steps := c.steps()
+	for i, step := range c.AdminSteps {
+		if step == steps[0] || !contains(steps, step) {
+			problems.Addf(fmt.Sprintf("admin_steps[%d]", i), "%q must be one of steps but the first", step)
+		}
+	}
+	return problems.Err()
+}
+
+func contains(steps []string, step string) bool {
+	for _, s := range steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+func validStep(step string) bool {
+	if step == "" {
+		return false
+	}
+	for _, c := range step {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package onboarding
+
+import (
+	"fmt"
+	"github.com/api_base/internal/domain/fault"
+)
+
+// StateMachine validates the transitions between the onboarding steps, each step
+// moves to the next one only.
+type StateMachine struct {
+	steps []string
+	index map[string]int
+	admin map[string]bool
+}
+
+func NewStateMachine(config Config) *StateMachine {
+	steps := config.steps()
+	index := make(map[string]int, len(steps))
+	for i, step := range steps {
+		index[step] = i
+	}
+	admin := map[string]bool{}
+	for _, step := range config.adminSteps() {
+		admin[step] = true
+	}
+	return &StateMachine{steps: steps, index: index, admin: admin}
+}
+
+// Initial returns the step of users that didn't start onboarding.
+func (m *StateMachine) Initial() string {
+	return m.steps[0]
+}
+
+// Final reports whether step ends onboarding.
+func (m *StateMachine) Final(step string) bool {
+	return step == m.steps[len(m.steps)-1]
+}
+
+// Admin reports whether moving to step requires an admin, see Config.
+func (m *StateMachine) Admin(step string) bool {
+	return m.admin[step]
+}
+
+// Next returns the step following step, false for the last one and unknown steps.
+func (m *StateMachine) Next(step string) (string, bool) {
+	i, ok := m.index[step]
+	if !ok || i == len(m.steps)-1 {
+		return "", false
+	}
+	return m.steps[i+1], true
+}
+
+// Transition checks that from may move to to.
+func (m *StateMachine) Transition(from, to string) error {
+	if _, ok := m.index[to]; !ok {
+		return fault.Invalid("unknown_step", fault.Violation{
+			Field: "step", Rule: "known_step", Message: fmt.Sprintf("step %q is not an onboarding step", to),
+		})
+	}
+	if next, ok := m.Next(from); !ok || next != to {
+		return &fault.Error{Kind: fault.Conflict, Message: "invalid_transition", Violations: []fault.Violation{{
+			Field: "step", Rule: "next_step", Message: fmt.Sprintf("step %q can't move to %q", from, to),
+		}}}
+	}
+	return nil
+}
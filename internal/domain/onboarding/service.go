@@ -0,0 +1,121 @@
+package onboarding
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/event"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"time"
+)
+
+var (
+	errCompleted = fault.New(fault.Conflict, "onboarding_completed")
+	errAdminStep = fault.New(fault.Forbidden, "admin_step")
+)
+
+// Store persists the onboarding of users. Save stores onboarding, appends transition
+// to its history and records events in the outbox in a single transaction; it fails
+// with a fault.Conflict when the stored version is not onboarding.Version - 1, i.e.
+// another transition won.
+type Store interface {
+	Get(ctx context.Context, userID model.UserID) (model.Onboarding, error)
+	Save(ctx context.Context, onboarding model.Onboarding, transition model.OnboardingTransition, events []model.Event) error
+}
+
+// UserGetter tells whether a user exists.
+type UserGetter interface {
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+}
+
+type Service interface {
+	Get(ctx context.Context, userID model.UserID) (model.Onboarding, error)
+	// Advance moves the user to step, or to the next step when step is empty.
+	Advance(ctx context.Context, userID model.UserID, step string) (model.Onboarding, error)
+}
+
+type service struct {
+	store   Store
+	users   UserGetter
+	machine *StateMachine
+	policy  *auth.Policy
+	now     func() time.Time
+}
+
+func NewService(store Store, users UserGetter, machine *StateMachine, policy *auth.Policy) Service {
+	return &service{
+		store:   store,
+		users:   users,
+		machine: machine,
+		policy:  policy,
+		now:     time.Now,
+	}
+}
+
+// Get returns the onboarding of the user, at the initial step when it didn't start.
+func (s service) Get(ctx context.Context, userID model.UserID) (model.Onboarding, error) {
+	if _, err := s.users.Get(ctx, userID); err != nil {
+		return model.Onboarding{}, err
+	}
+	return s.current(ctx, userID)
+}
+
+// Advance records the onboarding.advanced event of the transition with it.
+func (s service) Advance(ctx context.Context, userID model.UserID, step string) (model.Onboarding, error) {
+	if _, err := s.users.Get(ctx, userID); err != nil {
+		return model.Onboarding{}, err
+	}
+	onboarding, err := s.current(ctx, userID)
+	if err != nil {
+		return model.Onboarding{}, err
+	}
+	if step == "" && onboarding.Completed {
+		return model.Onboarding{}, errCompleted
+	}
+	if step == "" {
+		step = onboarding.NextStep
+	}
+	if err := s.machine.Transition(onboarding.Step, step); err != nil {
+		return model.Onboarding{}, err
+	}
+	p, authenticated := auth.FromContext(ctx)
+	if authenticated && s.machine.Admin(step) && !s.policy.Allowed(p, auth.OnboardingWrite, 0) {
+		return model.Onboarding{}, errAdminStep
+	}
+	var actor model.UserID
+	if authenticated {
+		actor = p.UserID
+	}
+	now := s.now().UTC()
+	transition := model.OnboardingTransition{From: onboarding.Step, To: step, Actor: actor, At: now}
+	onboarding.Step = step
+	onboarding.Version++
+	onboarding.UpdatedAt = &now
+	onboarding.History = append(onboarding.History, transition)
+	advanced, err := event.OnboardingAdvanced(userID, transition, s.machine.Final(step))
+	if err != nil {
+		return model.Onboarding{}, err
+	}
+	if err := s.store.Save(ctx, onboarding, transition, []model.Event{advanced}); err != nil {
+		return model.Onboarding{}, err
+	}
+	s.describe(&onboarding)
+	return onboarding, nil
+}
+
+func (s service) current(ctx context.Context, userID model.UserID) (model.Onboarding, error) {
+	onboarding, err := s.store.Get(ctx, userID)
+	if fault.Is(err, fault.NotFound) {
+		onboarding, err = model.Onboarding{UserID: userID, Step: s.machine.Initial(), History: []model.OnboardingTransition{}}, nil
+	}
+	if err != nil {
+		return model.Onboarding{}, err
+	}
+	s.describe(&onboarding)
+	return onboarding, nil
+}
+
+func (s service) describe(onboarding *model.Onboarding) {
+	onboarding.NextStep, _ = s.machine.Next(onboarding.Step)
+	onboarding.Completed = s.machine.Final(onboarding.Step)
+}
@@ -0,0 +1,136 @@
+package onboarding
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func initTest() (context.Context, *service, *memory.Outbox, *time.Time) {
+	users := memory.NewUserRepository(nil, model.User{Id: 1})
+	outbox := memory.NewOutbox()
+	policy := auth.NewPolicy(auth.PolicyConfig{
+		Roles:        map[string][]string{"admin": {"onboarding:write"}, "user": {"onboarding:write:self"}},
+		DefaultRoles: []string{"user"},
+	})
+	srv := NewService(memory.NewOnboardingStore(outbox), users, NewStateMachine(Config{}), policy).(*service)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv.now = func() time.Time { return now }
+	return auth.NewContext(context.Background(), auth.Principal{UserID: 9, Roles: []string{"admin"}}), srv, outbox, &now
+}
+
+func TestStateMachine(t *testing.T) {
+	m := NewStateMachine(Config{Steps: []string{"created", "verified", "active"}})
+
+	assert.Equal(t, "created", m.Initial())
+	assert.True(t, m.Final("active"))
+	next, ok := m.Next("created")
+	assert.Equal(t, "verified", next)
+	assert.True(t, ok)
+	_, ok = m.Next("active")
+	assert.False(t, ok)
+
+	assert.Nil(t, m.Transition("created", "verified"))
+	assert.True(t, fault.Is(m.Transition("created", "active"), fault.Conflict))
+	assert.True(t, fault.Is(m.Transition("verified", "created"), fault.Conflict))
+	assert.True(t, fault.Is(m.Transition("created", "unknown"), fault.InvalidArgument))
+}
+
+func TestService_Get(t *testing.T) {
+	ctx, srv, _, _ := initTest()
+
+	onboarding, err := srv.Get(ctx, 1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.Onboarding{UserID: 1, Step: "created", NextStep: "profile_completed", History: []model.OnboardingTransition{}}, onboarding)
+	_, err = srv.Get(ctx, 2)
+	assert.Equal(t, model.ErrUserNotFound, err)
+}
+
+func TestService_Advance(t *testing.T) {
+	ctx, srv, outbox, now := initTest()
+
+	onboarding, err := srv.Advance(ctx, 1, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "profile_completed", onboarding.Step)
+	assert.Equal(t, "token_issued", onboarding.NextStep)
+	onboarding, err = srv.Advance(ctx, 1, "token_issued")
+	assert.Nil(t, err)
+	_, err = srv.Advance(ctx, 1, "active")
+	assert.True(t, fault.Is(err, fault.Conflict))
+	_, _ = srv.Advance(ctx, 1, "")
+	onboarding, err = srv.Advance(ctx, 1, "")
+	assert.Nil(t, err)
+	assert.True(t, onboarding.Completed)
+	assert.Empty(t, onboarding.NextStep)
+	_, err = srv.Advance(ctx, 1, "")
+	assert.True(t, fault.Is(err, fault.Conflict))
+
+	stored, _ := srv.Get(ctx, 1)
+	assert.Len(t, stored.History, 4)
+	assert.Equal(t, model.OnboardingTransition{From: "created", To: "profile_completed", Actor: 9, At: *now}, stored.History[0])
+	events := outbox.Events()
+	assert.Len(t, events, 4)
+	assert.Equal(t, model.EventOnboardingAdvanced, events[3].Type)
+	assert.Equal(t, "1", events[3].AggregateID)
+	assert.Equal(t, *now, events[3].OccurredAt)
+	assert.JSONEq(t, `{"user_id":1,"from":"verified","to":"active","completed":true,"actor":9}`, string(events[3].Payload))
+}
+
+func TestService_Advance_AdminSteps(t *testing.T) {
+	_, srv, outbox, _ := initTest()
+	self := auth.NewContext(context.Background(), auth.Principal{UserID: 1, Roles: []string{"user"}})
+
+	_, err := srv.Advance(self, 1, "")
+	assert.Nil(t, err)
+	onboarding, err := srv.Advance(self, 1, "token_issued")
+	assert.Nil(t, err)
+	assert.Equal(t, "verified", onboarding.NextStep)
+
+	_, err = srv.Advance(self, 1, "")
+	assert.Equal(t, errAdminStep, err, "users don't verify themselves")
+	onboarding, err = srv.Advance(context.Background(), 1, "verified")
+	assert.Nil(t, err, "without authentication every step is open")
+	_, err = srv.Advance(self, 1, "active")
+	assert.True(t, fault.Is(err, fault.Forbidden))
+	assert.Len(t, outbox.Events(), 3, "denied transitions emit no event")
+}
+
+func TestService_Advance_Conflict(t *testing.T) {
+	ctx, srv, outbox, _ := initTest()
+	_, err := srv.Advance(ctx, 1, "")
+	assert.Nil(t, err)
+	stale, _ := srv.store.Get(ctx, 1)
+	_, err = srv.Advance(ctx, 1, "")
+	assert.Nil(t, err)
+
+	stale.Step, stale.Version = "token_issued", stale.Version+1
+	err = srv.store.Save(ctx, stale, model.OnboardingTransition{}, []model.Event{{ID: "stale"}})
+
+	assert.True(t, fault.Is(err, fault.Conflict))
+	assert.Len(t, outbox.Events(), 2, "the event of a lost transition is not recorded")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+	err := Config{Steps: []string{"created", "Verified", "created"}}.Validate()
+	assert.Contains(t, err.Error(), `steps[1]: "Verified" must be lowercase letters, digits and underscores`)
+	assert.Contains(t, err.Error(), `steps[2]: duplicated step "created"`)
+	assert.Contains(t, Config{Steps: []string{"created"}}.Validate().Error(), "steps: must list at least 2 steps")
+
+	assert.Nil(t, Config{AdminSteps: []string{"verified"}}.Validate())
+	err = Config{Steps: []string{"created", "active"}, AdminSteps: []string{"created", "verified"}}.Validate()
+	assert.Contains(t, err.Error(), `admin_steps[0]: "created" must be one of steps but the first`)
+	assert.Contains(t, err.Error(), `admin_steps[1]: "verified" must be one of steps but the first`)
+}
+
+func TestStateMachine_Admin(t *testing.T) {
+	assert.True(t, NewStateMachine(Config{}).Admin("verified"))
+	assert.False(t, NewStateMachine(Config{}).Admin("token_issued"))
+	assert.False(t, NewStateMachine(Config{Steps: []string{"created", "verified"}}).Admin("verified"), "custom steps list their admin steps")
+}
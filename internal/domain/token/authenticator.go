@@ -0,0 +1,42 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/model"
+	"strings"
+)
+
+// AccessTokenVerifier verifies self-contained access tokens.
+type AccessTokenVerifier interface {
+	Verify(ctx context.Context, raw string) (Claims, error)
+}
+
+type jwtAuthenticator struct {
+	verifier AccessTokenVerifier
+	fallback auth.Authenticator
+}
+
+// NewJWTAuthenticator creates an auth.Authenticator verifying JWT credentials locally
+// and delegating any other credential to fallback.
+func NewJWTAuthenticator(verifier AccessTokenVerifier, fallback auth.Authenticator) auth.Authenticator {
+	return &jwtAuthenticator{
+		verifier: verifier,
+		fallback: fallback,
+	}
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, credential string) (auth.Principal, error) {
+	if strings.Count(credential, ".") != 2 {
+		return a.fallback.Authenticate(ctx, credential)
+	}
+	claims, err := a.verifier.Verify(ctx, credential)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	userID, err := model.ParseUserID(claims.Subject)
+	if err != nil {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	return auth.Principal{UserID: userID, Roles: claims.Roles, Scopes: claims.Scopes, Method: auth.MethodJWT}, nil
+}
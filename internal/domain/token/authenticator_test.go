@@ -0,0 +1,54 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/tool/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type verifierMock struct {
+	mock.Mock
+}
+
+func (vm *verifierMock) Verify(ctx context.Context, raw string) (Claims, error) {
+	args := vm.Called(ctx, raw)
+	return args.Get(0).(Claims), args.Error(1)
+}
+
+type authenticatorMock struct {
+	mock.Mock
+}
+
+func (am *authenticatorMock) Authenticate(ctx context.Context, credential string) (auth.Principal, error) {
+	args := am.Called(ctx, credential)
+	return args.Get(0).(auth.Principal), args.Error(1)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	verifier, fallback := &verifierMock{}, &authenticatorMock{}
+	verifier.On("Verify", ctx, "a.b.c").Return(Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "7"}, Roles: []string{"admin"}, Scopes: []string{"users:read"}}, nil)
+	verifier.On("Verify", ctx, "x.y.z").Return(Claims{}, fault.New(fault.Unauthorized, "invalid_access_token"))
+	verifier.On("Verify", ctx, "n.o.sub").Return(Claims{}, nil)
+	fallback.On("Authenticate", ctx, "opaque").Return(auth.Principal{UserID: 9}, nil)
+	a := NewJWTAuthenticator(verifier, fallback)
+
+	p, err := a.Authenticate(ctx, "a.b.c")
+	assert.Nil(t, err)
+	assert.Equal(t, auth.Principal{UserID: 7, Roles: []string{"admin"}, Scopes: []string{"users:read"}, Method: auth.MethodJWT}, p)
+
+	p, err = a.Authenticate(ctx, "opaque")
+	assert.Nil(t, err)
+	assert.Equal(t, auth.Principal{UserID: 9}, p)
+
+	_, err = a.Authenticate(ctx, "x.y.z")
+	assert.True(t, fault.Is(err, fault.Unauthorized))
+
+	_, err = a.Authenticate(ctx, "n.o.sub")
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+	fallback.AssertNumberOfCalls(t, "Authenticate", 1)
+}
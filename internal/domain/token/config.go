@@ -0,0 +1,74 @@
+package token
+
+import (
+	"github.com/api_base/tool/validation"
+	"time"
+)
+
+// default values
+const (
+	defaultTTL    = 30 * 24 * time.Hour
+	defaultMaxTTL = 365 * 24 * time.Hour
+)
+
+// LifecycleConfig bounds the tokens issued by the api. Scopes lists the scopes tokens
+// may hold, DefaultScopes the ones of tokens requested without scopes, all of them
+// when empty.
+type LifecycleConfig struct {
+	DefaultTTLSeconds time.Duration `yaml:"default_ttl_seconds"`
+	MaxTTLSeconds     time.Duration `yaml:"max_ttl_seconds"`
+	Scopes            []string      `yaml:"scopes"`
+	DefaultScopes     []string      `yaml:"default_scopes"`
+}
+
+// DefaultTTL returns the lifetime of tokens requested without one.
+func (c LifecycleConfig) DefaultTTL() time.Duration {
+	if c.DefaultTTLSeconds > 0 {
+		return c.DefaultTTLSeconds * time.Second
+	}
+	return defaultTTL
+}
+
+// MaxTTL returns the longest lifetime a token may be requested with.
+func (c LifecycleConfig) MaxTTL() time.Duration {
+	if c.MaxTTLSeconds > 0 {
+		return c.MaxTTLSeconds * time.Second
+	}
+	return defaultMaxTTL
+}
+
+func (c LifecycleConfig) defaultScopes() []string {
+	if len(c.DefaultScopes) > 0 {
+		return c.DefaultScopes
+	}
+	return c.Scopes
+}
+
+// Validate reports every problem of the config.
+func (c LifecycleConfig) Validate() error {
+	problems := validation.Problems{}
+	if c.DefaultTTLSeconds < 0 {
+		problems.Addf("default_ttl_seconds", "must not be negative")
+	}
+	if c.MaxTTLSeconds < 0 {
+		problems.Addf("max_ttl_seconds", "must not be negative")
+	}
+	if c.DefaultTTL() > c.MaxTTL() {
+		problems.Addf("default_ttl_seconds", "must not exceed max_ttl_seconds")
+	}
+	for i, scope := range c.DefaultScopes {
+		if !contains(c.Scopes, scope) {
+			problems.Addf("default_scopes", "scope %q at %d is not in scopes", scope, i)
+		}
+	}
+	return problems.Err()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/event"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"sort"
+	"time"
+)
+
+const (
+	// valuePrefix tells the tokens issued by the api apart, they never contain dots
+	// so they are not mistaken for access tokens.
+	valuePrefix = "at_"
+	valueBytes  = 32
+)
+
+var ErrTokenNotFound = fault.New(fault.NotFound, "token_not_found")
+
+// Store persists the tokens issued by the api. Save writes records, inserting or
+// replacing them by ID, appends the audit entries and records the events in the
+// outbox in a single transaction.
+type Store interface {
+	Save(ctx context.Context, records []model.TokenRecord, audit []model.TokenAudit, events []model.Event) error
+	Get(ctx context.Context, id string) (model.TokenRecord, error)
+	FindByHash(ctx context.Context, hash string) (model.TokenRecord, error)
+	ListByUser(ctx context.Context, userID model.UserID) ([]model.TokenRecord, error)
+}
+
+// UserGetter tells whether a user exists.
+type UserGetter interface {
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+}
+
+// Request describes a token to issue, zero values take the defaults of the config.
+type Request struct {
+	Scopes []string
+	TTL    time.Duration
+}
+
+// Lifecycle issues opaque tokens to users and revokes them. Only a hash of the
+// values is stored, every change is recorded in the audit trail.
+type Lifecycle interface {
+	Create(ctx context.Context, userID model.UserID, req Request) (model.IssuedToken, error)
+	Revoke(ctx context.Context, userID model.UserID, tokenID string) error
+	Rotate(ctx context.Context, userID model.UserID, req Request) (model.IssuedToken, error)
+	FindByValue(ctx context.Context, value string) (model.Token, error)
+}
+
+type lifecycle struct {
+	store  Store
+	users  UserGetter
+	config LifecycleConfig
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+func NewLifecycle(store Store, users UserGetter, config LifecycleConfig, lg *logger.Logger) Lifecycle {
+	return &lifecycle{
+		store:  store,
+		users:  users,
+		config: config,
+		logger: lg,
+		now:    time.Now,
+	}
+}
+
+// Create issues a new token to the user, actor is taken from ctx.
+func (l lifecycle) Create(ctx context.Context, userID model.UserID, req Request) (model.IssuedToken, error) {
+	if _, err := l.users.Get(ctx, userID); err != nil {
+		return model.IssuedToken{}, err
+	}
+	issued, record, err := l.newToken(userID, req)
+	if err != nil {
+		return model.IssuedToken{}, err
+	}
+	audit := []model.TokenAudit{l.audit(ctx, record, model.TokenEventIssued)}
+	if err := l.store.Save(ctx, []model.TokenRecord{record}, audit, nil); err != nil {
+		return model.IssuedToken{}, err
+	}
+	l.log(ctx, audit)
+	return issued, nil
+}
+
+// Revoke revokes the token of the user, revoking a revoked token does nothing. Tokens
+// of other users are not found.
+func (l lifecycle) Revoke(ctx context.Context, userID model.UserID, tokenID string) error {
+	record, err := l.store.Get(ctx, tokenID)
+	if fault.Is(err, fault.NotFound) || (err == nil && record.UserID != userID) {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if record.Status == model.TokenStatusRevoked {
+		return nil
+	}
+	l.revoke(&record)
+	audit := []model.TokenAudit{l.audit(ctx, record, model.TokenEventRevoked)}
+	events, err := revokedEvents(audit)
+	if err != nil {
+		return err
+	}
+	if err := l.store.Save(ctx, []model.TokenRecord{record}, audit, events); err != nil {
+		return err
+	}
+	l.log(ctx, audit)
+	return nil
+}
+
+// Rotate revokes every active token of the user and issues a new one. Without
+// scopes in req the new token keeps the scopes of the newest revoked token.
+func (l lifecycle) Rotate(ctx context.Context, userID model.UserID, req Request) (model.IssuedToken, error) {
+	if _, err := l.users.Get(ctx, userID); err != nil {
+		return model.IssuedToken{}, err
+	}
+	records, err := l.store.ListByUser(ctx, userID)
+	if err != nil {
+		return model.IssuedToken{}, err
+	}
+	now := l.now()
+	var active []model.TokenRecord
+	for _, record := range records {
+		if record.Status == model.TokenStatusActive && now.Before(record.ExpiresAt) {
+			active = append(active, record)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	if len(req.Scopes) == 0 && len(active) > 0 {
+		req.Scopes = active[len(active)-1].Scopes
+	}
+	issued, record, err := l.newToken(userID, req)
+	if err != nil {
+		return model.IssuedToken{}, err
+	}
+	audit := make([]model.TokenAudit, 0, len(active)+1)
+	for i := range active {
+		l.revoke(&active[i])
+		audit = append(audit, l.audit(ctx, active[i], model.TokenEventRotated))
+	}
+	events, err := revokedEvents(audit)
+	if err != nil {
+		return model.IssuedToken{}, err
+	}
+	audit = append(audit, l.audit(ctx, record, model.TokenEventIssued))
+	if err := l.store.Save(ctx, append(active, record), audit, events); err != nil {
+		return model.IssuedToken{}, err
+	}
+	l.log(ctx, audit)
+	return issued, nil
+}
+
+// FindByValue looks up the token whose value is exactly value, whatever its status.
+func (l lifecycle) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	record, err := l.store.FindByHash(ctx, Hash(value))
+	if err != nil {
+		return model.Token{}, err
+	}
+	return record.Token(value), nil
+}
+
+func (l lifecycle) newToken(userID model.UserID, req Request) (model.IssuedToken, model.TokenRecord, error) {
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = l.config.defaultScopes()
+	}
+	if len(scopes) == 0 {
+		return model.IssuedToken{}, model.TokenRecord{}, fault.Invalid("invalid_scope", fault.Violation{
+			Field: "scopes", Rule: "required", Message: "at least one scope is required",
+		})
+	}
+	for _, scope := range scopes {
+		if !contains(l.config.Scopes, scope) {
+			return model.IssuedToken{}, model.TokenRecord{}, fault.Invalid("invalid_scope", fault.Violation{
+				Field: "scopes", Rule: "allowed_scope", Message: fmt.Sprintf("scope %q is not allowed", scope),
+			})
+		}
+	}
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = l.config.DefaultTTL()
+	}
+	if ttl < 0 || ttl > l.config.MaxTTL() {
+		return model.IssuedToken{}, model.TokenRecord{}, fault.Invalid("invalid_ttl", fault.Violation{
+			Field: "ttl_seconds", Rule: "range", Message: fmt.Sprintf("ttl must be between 1 and %d seconds", int64(l.config.MaxTTL()/time.Second)),
+		})
+	}
+	id, err := newTokenID()
+	if err != nil {
+		return model.IssuedToken{}, model.TokenRecord{}, err
+	}
+	value, err := randomValue()
+	if err != nil {
+		return model.IssuedToken{}, model.TokenRecord{}, err
+	}
+	now := l.now().UTC().Truncate(time.Second)
+	record := model.TokenRecord{
+		ID:        id,
+		UserID:    userID,
+		Hash:      Hash(value),
+		Scopes:    append([]string{}, scopes...),
+		Status:    model.TokenStatusActive,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	return model.IssuedToken{
+		ID:        record.ID,
+		Token:     value,
+		UserID:    userID,
+		Scopes:    record.Scopes,
+		Status:    record.Status,
+		ExpiresAt: record.ExpiresAt,
+	}, record, nil
+}
+
+func (l lifecycle) revoke(record *model.TokenRecord) {
+	now := l.now().UTC().Truncate(time.Second)
+	record.Status = model.TokenStatusRevoked
+	record.RevokedAt = &now
+}
+
+func (l lifecycle) audit(ctx context.Context, record model.TokenRecord, event model.TokenEvent) model.TokenAudit {
+	p, _ := auth.FromContext(ctx)
+	return model.TokenAudit{
+		TokenID: record.ID,
+		UserID:  record.UserID,
+		Event:   event,
+		Actor:   p.UserID,
+		At:      l.now().UTC(),
+	}
+}
+
+// revokedEvents returns a token.revoked event per entry of audit, all revoking a token.
+func revokedEvents(audit []model.TokenAudit) ([]model.Event, error) {
+	events := make([]model.Event, 0, len(audit))
+	for _, entry := range audit {
+		e, err := event.TokenRevoked(entry)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (l lifecycle) log(ctx context.Context, audit []model.TokenAudit) {
+	for _, entry := range audit {
+		l.logger.Info(ctx, "token "+string(entry.Event),
+			logger.F("token_id", entry.TokenID),
+			logger.F("user_id", entry.UserID.Int64()),
+			logger.F("actor", entry.Actor.Int64()),
+		)
+	}
+}
+
+// Hash returns the hash stored in place of a token value. Values are random, so a
+// plain sha256 is enough to protect them at rest.
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, valueBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return valuePrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
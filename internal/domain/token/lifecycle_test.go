@@ -0,0 +1,133 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/memory"
+	"github.com/api_base/tool/logger"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initLifecycleTest() (context.Context, *memory.TokenStore, *lifecycle, *time.Time) {
+	store := memory.NewTokenStore(nil)
+	users := memory.NewUserRepository(nil, model.User{Id: 1}, model.User{Id: 2})
+	l := NewLifecycle(store, users, LifecycleConfig{
+		DefaultTTLSeconds: 3600,
+		MaxTTLSeconds:     7200,
+		Scopes:            []string{"users:read", "users:write"},
+		DefaultScopes:     []string{"users:read"},
+	}, logger.Discard()).(*lifecycle)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return auth.NewContext(context.Background(), auth.Principal{UserID: 9}), store, l, &now
+}
+
+func TestLifecycle_Create(t *testing.T) {
+	ctx, store, l, now := initLifecycleTest()
+
+	issued, err := l.Create(ctx, 1, Request{})
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(issued.Token, valuePrefix))
+	assert.NotContains(t, issued.Token, ".")
+	assert.Equal(t, []string{"users:read"}, issued.Scopes)
+	assert.Equal(t, now.Add(time.Hour), issued.ExpiresAt)
+	record, _ := store.Get(ctx, issued.ID)
+	assert.Equal(t, Hash(issued.Token), record.Hash)
+	assert.NotContains(t, record.Hash, issued.Token)
+	assert.Equal(t, []model.TokenAudit{{TokenID: issued.ID, UserID: 1, Event: model.TokenEventIssued, Actor: 9, At: *now}}, store.Audit())
+
+	token, err := l.FindByValue(ctx, issued.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", token.UserId)
+	assert.True(t, token.Active(*now))
+	assert.False(t, token.Active(now.Add(time.Hour)))
+}
+
+func TestLifecycle_Create_Invalid(t *testing.T) {
+	ctx, store, l, _ := initLifecycleTest()
+
+	_, err := l.Create(ctx, 1, Request{Scopes: []string{"admin"}})
+	assert.True(t, fault.Is(err, fault.InvalidArgument))
+	_, err = l.Create(ctx, 1, Request{TTL: 3 * time.Hour})
+	assert.True(t, fault.Is(err, fault.InvalidArgument))
+	_, err = l.Create(ctx, 3, Request{})
+	assert.Equal(t, model.ErrUserNotFound, err)
+	assert.Empty(t, store.Audit())
+}
+
+func TestLifecycle_Revoke(t *testing.T) {
+	ctx, store, l, now := initLifecycleTest()
+	issued, _ := l.Create(ctx, 1, Request{})
+
+	assert.Equal(t, ErrTokenNotFound, l.Revoke(ctx, 2, issued.ID))
+	assert.Equal(t, ErrTokenNotFound, l.Revoke(ctx, 1, "unknown"))
+	assert.Nil(t, l.Revoke(ctx, 1, issued.ID))
+	assert.Nil(t, l.Revoke(ctx, 1, issued.ID))
+
+	token, _ := l.FindByValue(ctx, issued.Token)
+	assert.False(t, token.Active(*now))
+	audit := store.Audit()
+	assert.Len(t, audit, 2)
+	assert.Equal(t, model.TokenEventRevoked, audit[1].Event)
+}
+
+func TestLifecycle_Rotate(t *testing.T) {
+	ctx, store, l, now := initLifecycleTest()
+	first, _ := l.Create(ctx, 1, Request{Scopes: []string{"users:read", "users:write"}})
+	*now = now.Add(time.Minute)
+	second, _ := l.Create(ctx, 1, Request{Scopes: []string{"users:write"}})
+	other, _ := l.Create(ctx, 2, Request{})
+
+	rotated, err := l.Rotate(ctx, 1, Request{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"users:write"}, rotated.Scopes)
+	for _, value := range []string{first.Token, second.Token} {
+		token, _ := l.FindByValue(ctx, value)
+		assert.Equal(t, model.TokenStatusRevoked, token.Status)
+	}
+	token, _ := l.FindByValue(ctx, other.Token)
+	assert.True(t, token.Active(*now))
+	token, _ = l.FindByValue(ctx, rotated.Token)
+	assert.True(t, token.Active(*now))
+	var events []model.TokenEvent
+	for _, entry := range store.Audit()[3:] {
+		events = append(events, entry.Event)
+	}
+	assert.Equal(t, []model.TokenEvent{model.TokenEventRotated, model.TokenEventRotated, model.TokenEventIssued}, events)
+}
+
+func TestLifecycle_RevokeEmitsEvents(t *testing.T) {
+	outbox := memory.NewOutbox()
+	users := memory.NewUserRepository(nil, model.User{Id: 1})
+	l := NewLifecycle(memory.NewTokenStore(outbox), users, LifecycleConfig{Scopes: []string{"users:read"}}, logger.Discard())
+	ctx := auth.NewContext(context.Background(), auth.Principal{UserID: 9})
+	first, _ := l.Create(ctx, 1, Request{})
+	second, _ := l.Create(ctx, 1, Request{})
+
+	assert.Nil(t, l.Revoke(ctx, 1, first.ID))
+	_, err := l.Rotate(ctx, 1, Request{})
+	assert.Nil(t, err)
+
+	events := outbox.Events()
+	assert.Len(t, events, 2, "issuing a token emits no event")
+	assert.Equal(t, model.EventTokenRevoked, events[0].Type)
+	assert.Equal(t, first.ID, events[0].AggregateID)
+	assert.JSONEq(t, `{"token_id":"`+first.ID+`","user_id":1,"reason":"revoked","actor":9}`, string(events[0].Payload))
+	assert.Equal(t, second.ID, events[1].AggregateID)
+	assert.Contains(t, string(events[1].Payload), `"reason":"rotated"`)
+}
+
+func TestLifecycleConfig_Validate(t *testing.T) {
+	assert.Nil(t, LifecycleConfig{}.Validate())
+	assert.Nil(t, LifecycleConfig{Scopes: []string{"users:read"}, DefaultScopes: []string{"users:read"}}.Validate())
+
+	err := LifecycleConfig{DefaultTTLSeconds: 10, MaxTTLSeconds: 5, Scopes: []string{"users:read"}, DefaultScopes: []string{"users:write"}}.Validate()
+	assert.EqualError(t, err, `2 config problem(s): default_ttl_seconds: must not exceed max_ttl_seconds; default_scopes: scope "users:write" at 0 is not in scopes`)
+}
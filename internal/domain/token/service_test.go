@@ -0,0 +1,52 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/jwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func initTest(t *testing.T) (context.Context, *service) {
+	keys, err := jwt.NewKeySet(jwt.Config{
+		Issuer:     "api_base",
+		Audience:   "api_base",
+		TTLSeconds: 60,
+		SigningKid: "k1",
+		Keys:       []jwt.KeyConfig{{Kid: "k1", Alg: jwt.HS256, Secret: "secret"}},
+	})
+	assert.Nil(t, err)
+	return context.Background(), NewService(keys).(*service)
+}
+
+func TestService_IssueVerify(t *testing.T) {
+	ctx, srv := initTest(t)
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+
+	access, err := srv.Issue(ctx, model.User{Id: 7, Name: "seven", Roles: []string{"admin"}}, []string{"users:read"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer", access.Type)
+	assert.Equal(t, int64(60), access.ExpiresIn)
+
+	claims, err := srv.Verify(ctx, access.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "7", claims.Subject)
+	assert.Equal(t, "seven", claims.Name)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	assert.Equal(t, []string{"users:read"}, claims.Scopes)
+	assert.Equal(t, "api_base", claims.Issuer)
+	assert.Equal(t, now.Add(time.Minute).Unix(), claims.ExpiresAt)
+	assert.Len(t, claims.ID, 32)
+}
+
+func TestService_Verify_Invalid(t *testing.T) {
+	ctx, srv := initTest(t)
+
+	_, err := srv.Verify(ctx, "garbage")
+
+	assert.True(t, fault.Is(err, fault.Unauthorized))
+}
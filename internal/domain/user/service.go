@@ -2,33 +2,201 @@ package user
 
 import (
 	"context"
-	"github.com/api_base/internal/domain"
+	"fmt"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/event"
+	"github.com/api_base/internal/domain/fault"
 	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/tracing"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTokenConcurrency bounds the number of in-flight token_api calls of a batch lookup.
+	maxTokenConcurrency = 5
+	// maxNameLength is the size of the name column.
+	maxNameLength = 45
 )
 
 type Service interface {
-	Get(ctx context.Context, id int64) (*model.User, error)
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+	GetBatch(ctx context.Context, ids []model.UserID) ([]model.UserResult, error)
+	Create(ctx context.Context, user model.User) (*model.User, error)
+	Update(ctx context.Context, user model.User) (*model.User, error)
+}
+
+// UserFinder is the part of domain.UserRepository the service uses. Create and
+// Update record the events built by events in the outbox, in the transaction of the
+// change: Create passes the created user, Update the user before the change.
+type UserFinder interface {
+	Get(ctx context.Context, id model.UserID) (*model.User, error)
+	GetMany(ctx context.Context, ids []model.UserID) ([]model.User, error)
+	Create(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (model.User, error)
+	Update(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) error
+}
+
+// TokenGetter is the part of domain.TokenRepository the service uses.
+type TokenGetter interface {
+	Get(ctx context.Context, id model.UserID) (model.Token, error)
 }
 
 type service struct {
-	container domain.Container
+	users  UserFinder
+	tokens TokenGetter
+	logger *logger.Logger
+	now    func() time.Time
 }
 
-func NewService(container domain.Container) Service {
+func NewService(users UserFinder, tokens TokenGetter, lg *logger.Logger) Service {
 	return &service{
-		container,
+		users:  users,
+		tokens: tokens,
+		logger: lg,
+		now:    time.Now,
 	}
 }
 
-func (s service) Get(ctx context.Context, id int64) (*model.User, error) {
-	token, err := s.container.TokenRepo.Get(ctx, string(id))
+func (s service) Get(ctx context.Context, id model.UserID) (user *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Get", tracing.KindInternal)
+	span.SetAttribute("user.id", id.Int64())
+	defer func() { span.End(err) }()
+
+	token, err := s.tokens.Get(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	user, err := s.container.UserRepo.Get(ctx, id)
+	user, err = s.users.Get(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	user.Token = token
 	return user, nil
 }
+
+// GetBatch resolves many users with one repository query and fetches their tokens
+// concurrently. The result keeps the order of ids (duplicates removed) and carries
+// a per-id error instead of failing the whole batch.
+func (s service) GetBatch(ctx context.Context, ids []model.UserID) ([]model.UserResult, error) {
+	ids = uniqueIds(ids)
+	users, err := s.users.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[model.UserID]model.User, len(users))
+	for _, user := range users {
+		found[user.Id] = user
+	}
+
+	results := make([]model.UserResult, len(ids))
+	sem := make(chan struct{}, maxTokenConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		results[i].Id = id
+		user, exist := found[id]
+		if !exist {
+			results[i].Err = model.ErrUserNotFound
+			continue
+		}
+		wg.Add(1)
+		go func(result *model.UserResult, user model.User) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			token, err := s.tokens.Get(ctx, user.Id)
+			if err != nil {
+				s.logger.Warn(ctx, "batch token lookup fail", logger.F("user_id", user.Id.Int64()), logger.Err(err))
+				result.Err = err
+				return
+			}
+			user.Token = token
+			result.User = &user
+		}(&results[i], user)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Create stores a new user with the name and roles of user, emitting user.created.
+func (s service) Create(ctx context.Context, user model.User) (*model.User, error) {
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	created, err := s.users.Create(ctx, model.User{Name: user.Name, Roles: user.Roles}, func(created model.User) ([]model.Event, error) {
+		e, err := event.User(model.EventUserCreated, created, actor(ctx), s.now())
+		return []model.Event{e}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info(ctx, "user created", logger.F("user_id", created.Id.Int64()))
+	return &created, nil
+}
+
+// Update replaces the name and roles of the user, emitting user.updated when they
+// change.
+func (s service) Update(ctx context.Context, user model.User) (*model.User, error) {
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	user = model.User{Id: user.Id, Name: user.Name, Roles: user.Roles}
+	err := s.users.Update(ctx, user, func(previous model.User) ([]model.Event, error) {
+		if previous.Name == user.Name && sameRoles(previous.Roles, user.Roles) {
+			return nil, nil
+		}
+		e, err := event.User(model.EventUserUpdated, user, actor(ctx), s.now())
+		return []model.Event{e}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func validateUser(user model.User) error {
+	violations := []fault.Violation{}
+	if user.Name == "" {
+		violations = append(violations, fault.Violation{Field: "name", Rule: "required", Message: "name is required"})
+	}
+	if len(user.Name) > maxNameLength {
+		violations = append(violations, fault.Violation{Field: "name", Rule: "max_length", Message: fmt.Sprintf("name must be at most %d bytes", maxNameLength)})
+	}
+	for _, role := range user.Roles {
+		if role == "" || strings.Contains(role, ",") {
+			violations = append(violations, fault.Violation{Field: "roles", Rule: "role_name", Message: fmt.Sprintf("role %q must be non empty and without commas", role)})
+		}
+	}
+	if len(violations) > 0 {
+		return fault.Invalid("invalid_user", violations...)
+	}
+	return nil
+}
+
+func sameRoles(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// actor returns the authenticated user performing the change, zero when there is none.
+func actor(ctx context.Context) model.UserID {
+	p, _ := auth.FromContext(ctx)
+	return p.UserID
+}
+
+func uniqueIds(ids []model.UserID) []model.UserID {
+	seen := make(map[model.UserID]bool, len(ids))
+	unique := make([]model.UserID, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
@@ -1,56 +1,90 @@
 package user
 
 import (
+	"bytes"
 	"context"
-	"github.com/api_base/internal/domain"
+	"encoding/json"
+	"errors"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/event"
+	"github.com/api_base/internal/domain/fault"
 	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
 )
 
-type fakeContainer struct {
-	domain.Container
+type fakeDependencies struct {
 	UserRepoMock  *userRepositoryMock
 	TokenRepoMock *tokenRepositoryMock
+	LogOutput     *bytes.Buffer
+	Logger        *logger.Logger
 }
 
-func newContainerMock() *fakeContainer {
-	fc := &fakeContainer{
+func newDependenciesMock() *fakeDependencies {
+	fd := &fakeDependencies{
 		UserRepoMock:  &userRepositoryMock{},
 		TokenRepoMock: &tokenRepositoryMock{},
+		LogOutput:     &bytes.Buffer{},
 	}
-	fc.Container = domain.Container{
-		UserRepo:  fc.UserRepoMock,
-		TokenRepo: fc.TokenRepoMock,
-	}
-	return fc
+	fd.Logger, _ = logger.New(fd.LogOutput, logger.Config{})
+	return fd
 }
 
 type userRepositoryMock struct {
 	mock.Mock
+	// Events are the events recorded by Create and Update.
+	Events []model.Event
 }
 
-func (mr *userRepositoryMock) Get(ctx context.Context, id int64) (*model.User, error) {
+func (mr *userRepositoryMock) Get(ctx context.Context, id model.UserID) (*model.User, error) {
 	args := mr.Called(ctx, id)
 	res := args.Get(0).(*model.User)
 	return res, args.Error(1)
 }
 
+func (mr *userRepositoryMock) GetMany(ctx context.Context, ids []model.UserID) ([]model.User, error) {
+	args := mr.Called(ctx, ids)
+	res := args.Get(0).([]model.User)
+	return res, args.Error(1)
+}
+
+func (mr *userRepositoryMock) Create(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (model.User, error) {
+	args := mr.Called(ctx, user)
+	created := args.Get(0).(model.User)
+	if err := args.Error(1); err != nil {
+		return model.User{}, err
+	}
+	recorded, err := events(created)
+	mr.Events = append(mr.Events, recorded...)
+	return created, err
+}
+
+func (mr *userRepositoryMock) Update(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) error {
+	args := mr.Called(ctx, user)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	recorded, err := events(args.Get(0).(model.User))
+	mr.Events = append(mr.Events, recorded...)
+	return err
+}
+
 type tokenRepositoryMock struct {
 	mock.Mock
 }
 
-func (tk *tokenRepositoryMock) Get(ctx context.Context, id string) (model.Token, error) {
+func (tk *tokenRepositoryMock) Get(ctx context.Context, id model.UserID) (model.Token, error) {
 	args := tk.Called(ctx, id)
 	res := args.Get(0).(model.Token)
 	return res, args.Error(1)
 }
 
-func initTest() (context.Context, *fakeContainer, Service) {
-	ctn := newContainerMock()
-	srv := NewService(ctn.Container)
-	return context.Background(), ctn, srv
+func initTest() (context.Context, *fakeDependencies, Service) {
+	deps := newDependenciesMock()
+	srv := NewService(deps.UserRepoMock, deps.TokenRepoMock, deps.Logger)
+	return context.Background(), deps, srv
 }
 
 func TestService_Get(t *testing.T) {
@@ -58,12 +92,97 @@ func TestService_Get(t *testing.T) {
 
 	userDb := &model.User{Id: 1}
 	tokenResp := model.Token{Id: "token_1", UserId: "1"}
-	cnt.UserRepoMock.On("Get", ctx, 1).Return(userDb, nil)
-	cnt.TokenRepoMock.On("Get", ctx, "1").Return(tokenResp, nil)
+	// Get passes down a context carrying its span
+	cnt.UserRepoMock.On("Get", mock.Anything, model.UserID(1)).Return(userDb, nil)
+	cnt.TokenRepoMock.On("Get", mock.Anything, model.UserID(1)).Return(tokenResp, nil)
 
 	user, err := srv.Get(ctx, 1)
 
 	assert.Nil(t, err)
-	assert.Equal(t, 1, user.Id)
+	assert.Equal(t, model.UserID(1), user.Id)
 	assert.Equal(t, "token_1", user.Token.Id)
 }
+
+func TestService_GetBatch(t *testing.T) {
+	ctx, cnt, srv := initTest()
+
+	usersDb := []model.User{{Id: 1, Name: "one"}, {Id: 2, Name: "two"}}
+	cnt.UserRepoMock.On("GetMany", ctx, []model.UserID{1, 2, 3}).Return(usersDb, nil)
+	cnt.TokenRepoMock.On("Get", ctx, model.UserID(1)).Return(model.Token{Id: "token_1", UserId: "1"}, nil)
+	cnt.TokenRepoMock.On("Get", ctx, model.UserID(2)).Return(model.Token{}, errors.New("token_api_unavailable"))
+
+	results, err := srv.GetBatch(ctx, []model.UserID{1, 2, 3, 1})
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.Equal(t, model.UserID(1), results[0].Id)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "token_1", results[0].User.Token.Id)
+	assert.Equal(t, model.UserID(2), results[1].Id)
+	assert.Nil(t, results[1].User)
+	assert.EqualError(t, results[1].Err, "token_api_unavailable")
+	assert.Equal(t, model.UserID(3), results[2].Id)
+	assert.Equal(t, model.ErrUserNotFound, results[2].Err)
+	assert.Contains(t, cnt.LogOutput.String(), `"error":"token_api_unavailable","level":"warn","msg":"batch token lookup fail"`)
+	assert.Contains(t, cnt.LogOutput.String(), `"user_id":2`)
+}
+
+func TestService_GetBatch_RepositoryError(t *testing.T) {
+	ctx, cnt, srv := initTest()
+
+	cnt.UserRepoMock.On("GetMany", ctx, []model.UserID{1}).Return([]model.User{}, errors.New("db_down"))
+
+	results, err := srv.GetBatch(ctx, []model.UserID{1})
+
+	assert.Nil(t, results)
+	assert.EqualError(t, err, "db_down")
+}
+
+func TestService_Create(t *testing.T) {
+	ctx, cnt, srv := initTest()
+	ctx = auth.NewContext(ctx, auth.Principal{UserID: 9})
+	cnt.UserRepoMock.On("Create", ctx, model.User{Name: "new", Roles: []string{"user"}}).
+		Return(model.User{Id: 3, Name: "new", Roles: []string{"user"}}, nil)
+
+	user, err := srv.Create(ctx, model.User{Id: 5, Name: "new", Roles: []string{"user"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.UserID(3), user.Id)
+	assert.Len(t, cnt.UserRepoMock.Events, 1)
+	e := cnt.UserRepoMock.Events[0]
+	assert.Equal(t, model.EventUserCreated, e.Type)
+	assert.Equal(t, "3", e.AggregateID)
+	assert.Len(t, e.ID, 32)
+	payload := event.UserPayload{}
+	assert.Nil(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, event.UserPayload{ID: 3, Name: "new", Roles: []string{"user"}, Actor: 9}, payload)
+}
+
+func TestService_Create_Invalid(t *testing.T) {
+	ctx, cnt, srv := initTest()
+
+	_, err := srv.Create(ctx, model.User{Roles: []string{"a,b"}})
+
+	assert.True(t, fault.Is(err, fault.InvalidArgument))
+	assert.Len(t, err.(*fault.Error).Violations, 2)
+	cnt.UserRepoMock.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestService_Update(t *testing.T) {
+	ctx, cnt, srv := initTest()
+	cnt.UserRepoMock.On("Update", ctx, model.User{Id: 1, Name: "renamed"}).Return(model.User{Id: 1, Name: "one"}, nil)
+	cnt.UserRepoMock.On("Update", ctx, model.User{Id: 2, Name: "two"}).Return(model.User{Id: 2, Name: "two", Roles: []string{}}, nil)
+	cnt.UserRepoMock.On("Update", ctx, model.User{Id: 3, Name: "three"}).Return(model.User{}, model.ErrUserNotFound)
+
+	user, err := srv.Update(ctx, model.User{Id: 1, Name: "renamed"})
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", user.Name)
+	_, err = srv.Update(ctx, model.User{Id: 2, Name: "two"})
+	assert.Nil(t, err)
+	_, err = srv.Update(ctx, model.User{Id: 3, Name: "three"})
+	assert.Equal(t, model.ErrUserNotFound, err)
+
+	assert.Len(t, cnt.UserRepoMock.Events, 1, "unchanged users emit no event")
+	assert.Equal(t, model.EventUserUpdated, cnt.UserRepoMock.Events[0].Type)
+	assert.Equal(t, "1", cnt.UserRepoMock.Events[0].AggregateID)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"github.com/api_base/tool/validation"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Drivers
+const (
+	// DriverMySQL reads users from mysql and tokens from token_api.
+	DriverMySQL = "mysql"
+	// DriverMemory keeps users and tokens in memory, seeded from Fixture.
+	DriverMemory = "memory"
+)
+
+// Token read modes of the mysql driver
+const (
+	// ModeLocalFirst reads the local database, falling back to token_api for the tokens
+	// it lacks or when it is unavailable.
+	ModeLocalFirst = "local_first"
+	// ModeRemoteFirst reads token_api, falling back to the local database when token_api
+	// is unavailable.
+	ModeRemoteFirst = "remote_first"
+	// ModeRemoteOnly reads token_api only.
+	ModeRemoteOnly = "remote_only"
+)
+
+// Config selects the implementation of the user and token repositories.
+type Config struct {
+	Driver string `yaml:"driver"`
+	// Fixture is a .json, .yml or .yaml file seeding the memory repositories.
+	Fixture string       `yaml:"fixture"`
+	Tokens  TokensConfig `yaml:"tokens"`
+}
+
+// TokensConfig tells where the mysql driver reads tokens from. Unless the mode is
+// remote_only, the local database is synchronized from token_api every
+// sync_interval_seconds, 0 disables the synchronization.
+type TokensConfig struct {
+	Mode                string        `yaml:"mode"`
+	SyncIntervalSeconds time.Duration `yaml:"sync_interval_seconds"`
+}
+
+// SyncInterval returns the period of the token synchronization, 0 when it is disabled.
+func (c TokensConfig) SyncInterval() time.Duration {
+	if c.Mode == "" || c.Mode == ModeRemoteOnly {
+		return 0
+	}
+	return c.SyncIntervalSeconds * time.Second
+}
+
+// Memory reports whether the memory repositories are selected.
+func (c Config) Memory() bool {
+	return c.Driver == DriverMemory
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	switch c.Driver {
+	case "", DriverMySQL:
+		if c.Fixture != "" {
+			problems.Addf("fixture", "only the %s driver is seeded from a fixture", DriverMemory)
+		}
+		switch c.Tokens.Mode {
+		case "", ModeLocalFirst, ModeRemoteFirst, ModeRemoteOnly:
+		default:
+			problems.Addf("tokens.mode", "unknown mode %q, expected %s, %s or %s", c.Tokens.Mode, ModeLocalFirst, ModeRemoteFirst, ModeRemoteOnly)
+		}
+		if c.Tokens.SyncIntervalSeconds < 0 {
+			problems.Addf("tokens.sync_interval_seconds", "must not be negative")
+		}
+	case DriverMemory:
+		if c.Fixture == "" {
+			break
+		}
+		switch filepath.Ext(c.Fixture) {
+		case ".json", ".yml", ".yaml":
+		default:
+			problems.Addf("fixture", "unknown format of %s, expected .json, .yml or .yaml", c.Fixture)
+		}
+		if _, err := os.Stat(c.Fixture); err != nil {
+			problems.Addf("fixture", "%v", err)
+		}
+	default:
+		problems.Addf("driver", "unknown driver %q, expected %s or %s", c.Driver, DriverMySQL, DriverMemory)
+	}
+	return problems.Err()
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+	assert.Nil(t, Config{Driver: DriverMemory}.Validate())
+	assert.Nil(t, Config{Driver: DriverMemory, Fixture: "memory/testdata/fixture.json"}.Validate())
+
+	err := Config{Driver: "redis"}.Validate()
+	assert.EqualError(t, err, `1 config problem(s): driver: unknown driver "redis", expected mysql or memory`)
+	err = Config{Driver: DriverMySQL, Fixture: "memory/testdata/fixture.json"}.Validate()
+	assert.EqualError(t, err, "1 config problem(s): fixture: only the memory driver is seeded from a fixture")
+	err = Config{Driver: DriverMemory, Fixture: "memory/testdata/missing.txt"}.Validate()
+	assert.Contains(t, err.Error(), "2 config problem(s)")
+}
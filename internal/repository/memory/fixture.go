@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/api_base/internal/domain/model"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Fixture is the content of a seed file, e.g. in yaml:
+//
+//	users:
+//	  - id: 1
+//	    name: admin
+//	    roles: [admin]
+//	tokens:
+//	  - token: token_1
+//	    user_id: "1"
+type Fixture struct {
+	Users  []FixtureUser  `json:"users" yaml:"users"`
+	Tokens []FixtureToken `json:"tokens" yaml:"tokens"`
+}
+
+type FixtureUser struct {
+	Id    model.UserID `json:"id" yaml:"id"`
+	Name  string       `json:"name" yaml:"name"`
+	Roles []string     `json:"roles" yaml:"roles"`
+}
+
+type FixtureToken struct {
+	Token  string `json:"token" yaml:"token"`
+	UserId string `json:"user_id" yaml:"user_id"`
+}
+
+// LoadFixture reads the fixture at path, decoded as json or yaml by its extension.
+func LoadFixture(path string) (Fixture, error) {
+	fixture := Fixture{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixture, fmt.Errorf("read fixture fail: %w", err)
+	}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(content, &fixture)
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(content, &fixture)
+	default:
+		return fixture, fmt.Errorf("unknown fixture format %s", path)
+	}
+	if err != nil {
+		return fixture, fmt.Errorf("parse fixture %s fail: %w", path, err)
+	}
+	for _, user := range fixture.Users {
+		if !user.Id.Valid() {
+			return fixture, fmt.Errorf("fixture %s: invalid user id %d", path, user.Id)
+		}
+	}
+	for _, token := range fixture.Tokens {
+		if token.Token == "" {
+			return fixture, fmt.Errorf("fixture %s: empty token of user %s", path, token.UserId)
+		}
+	}
+	return fixture, nil
+}
+
+// UserModels returns the users of the fixture.
+func (f Fixture) UserModels() []model.User {
+	users := make([]model.User, len(f.Users))
+	for i, user := range f.Users {
+		users[i] = model.User{Id: user.Id, Name: user.Name, Roles: user.Roles}
+	}
+	return users
+}
+
+// TokenModels returns the tokens of the fixture.
+func (f Fixture) TokenModels() []model.Token {
+	tokens := make([]model.Token, len(f.Tokens))
+	for i, token := range f.Tokens {
+		tokens[i] = model.Token{Id: token.Token, UserId: token.UserId}
+	}
+	return tokens
+}
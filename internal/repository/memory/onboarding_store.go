@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"sync"
+)
+
+var (
+	errOnboardingNotFound = fault.New(fault.NotFound, "onboarding_not_found")
+	errOnboardingConflict = fault.New(fault.Conflict, "onboarding_changed")
+)
+
+// OnboardingStore keeps the onboarding of users in memory. It is safe for concurrent use.
+type OnboardingStore struct {
+	mu          sync.RWMutex
+	onboardings map[model.UserID]model.Onboarding
+	outbox      *Outbox
+}
+
+// NewOnboardingStore creates a store recording the events of the transitions in outbox,
+// which may be nil.
+func NewOnboardingStore(outbox *Outbox) *OnboardingStore {
+	return &OnboardingStore{onboardings: map[model.UserID]model.Onboarding{}, outbox: outbox}
+}
+
+func (s *OnboardingStore) Get(ctx context.Context, userID model.UserID) (model.Onboarding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	onboarding, ok := s.onboardings[userID]
+	if !ok {
+		return model.Onboarding{}, errOnboardingNotFound
+	}
+	return copyOnboarding(onboarding), nil
+}
+
+// Save stores onboarding, which carries transition in its history already, and
+// records events in the outbox.
+func (s *OnboardingStore) Save(ctx context.Context, onboarding model.Onboarding, transition model.OnboardingTransition, events []model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.onboardings[onboarding.UserID].Version != onboarding.Version-1 {
+		return errOnboardingConflict
+	}
+	s.onboardings[onboarding.UserID] = copyOnboarding(onboarding)
+	s.outbox.insert(events)
+	return nil
+}
+
+func copyOnboarding(onboarding model.Onboarding) model.Onboarding {
+	onboarding.History = append([]model.OnboardingTransition{}, onboarding.History...)
+	if onboarding.UpdatedAt != nil {
+		updatedAt := *onboarding.UpdatedAt
+		onboarding.UpdatedAt = &updatedAt
+	}
+	return onboarding
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/model"
+	"sync"
+	"time"
+)
+
+// Outbox keeps the events recorded by the memory repositories until the relay
+// publishes them. It is safe for concurrent use, a nil Outbox drops the events.
+type Outbox struct {
+	mu        sync.Mutex
+	events    []model.Event
+	published map[string]bool
+	dead      map[string]bool
+	attempts  map[string]int
+}
+
+func NewOutbox() *Outbox {
+	return &Outbox{published: map[string]bool{}, dead: map[string]bool{}, attempts: map[string]int{}}
+}
+
+func (o *Outbox) insert(events []model.Event) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, events...)
+}
+
+// Pending returns up to limit unpublished events, in the order they were recorded.
+func (o *Outbox) Pending(ctx context.Context, limit int) ([]model.Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	pending := []model.Event{}
+	for _, event := range o.events {
+		if len(pending) == limit {
+			break
+		}
+		if !o.published[event.ID] && !o.dead[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func (o *Outbox) MarkPublished(ctx context.Context, ids []string, at time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, id := range ids {
+		o.published[id] = true
+	}
+	return nil
+}
+
+func (o *Outbox) MarkFailed(ctx context.Context, id string, cause error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.attempts[id]++
+	return o.attempts[id], nil
+}
+
+func (o *Outbox) DeadLetter(ctx context.Context, id string, at time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dead[id] = true
+	return nil
+}
+
+// Events returns every recorded event, published or not.
+func (o *Outbox) Events() []model.Event {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]model.Event{}, o.events...)
+}
+
+// DeadLettered reports whether the event of id was dead lettered.
+func (o *Outbox) DeadLettered(id string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dead[id]
+}
+
+// Attempts returns the number of failed deliveries of the event of id.
+func (o *Outbox) Attempts(id string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.attempts[id]
+}
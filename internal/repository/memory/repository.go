@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"sync"
+)
+
+var errTokenNotFound = fault.New(fault.NotFound, "token_not_found")
+
+// UserRepository keeps users in memory. It is safe for concurrent use and returns
+// copies, so callers can't modify the stored users. The events of Create and Update
+// are recorded in its outbox.
+type UserRepository struct {
+	mu     sync.RWMutex
+	users  map[model.UserID]model.User
+	outbox *Outbox
+}
+
+func NewUserRepository(outbox *Outbox, users ...model.User) *UserRepository {
+	r := &UserRepository{users: make(map[model.UserID]model.User, len(users)), outbox: outbox}
+	for _, user := range users {
+		r.Save(user)
+	}
+	return r
+}
+
+// Save stores user, replacing the user with the same id. It records no event.
+func (r *UserRepository) Save(user model.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.Id] = copyUser(user)
+}
+
+// Create stores user with the id following the greatest one, and records the events
+// built from the created user.
+func (r *UserRepository) Create(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.Id = 1
+	for id := range r.users {
+		if id >= user.Id {
+			user.Id = id + 1
+		}
+	}
+	recorded, err := events(user)
+	if err != nil {
+		return model.User{}, err
+	}
+	r.users[user.Id] = copyUser(user)
+	r.outbox.insert(recorded)
+	return copyUser(user), nil
+}
+
+// Update replaces the name and roles of the user, and records the events built from
+// the user before the change.
+func (r *UserRepository) Update(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.users[user.Id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	recorded, err := events(copyUser(previous))
+	if err != nil {
+		return err
+	}
+	previous.Name, previous.Roles = user.Name, user.Roles
+	r.users[user.Id] = copyUser(previous)
+	r.outbox.insert(recorded)
+	return nil
+}
+
+func (r *UserRepository) Get(ctx context.Context, id model.UserID) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+	user = copyUser(user)
+	return &user, nil
+}
+
+// GetMany returns the users of ids in the order of ids, ids that do not exist are
+// absent from the result.
+func (r *UserRepository) GetMany(ctx context.Context, ids []model.UserID) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make([]model.User, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			users = append(users, copyUser(user))
+		}
+	}
+	return users, nil
+}
+
+func copyUser(user model.User) model.User {
+	if user.Roles != nil {
+		user.Roles = append([]string{}, user.Roles...)
+	}
+	return user
+}
+
+// TokenRepository keeps one token per user in memory. It is safe for concurrent use.
+type TokenRepository struct {
+	mu      sync.RWMutex
+	byUser  map[string]model.Token
+	byValue map[string]model.Token
+}
+
+func NewTokenRepository(tokens ...model.Token) *TokenRepository {
+	r := &TokenRepository{
+		byUser:  make(map[string]model.Token, len(tokens)),
+		byValue: make(map[string]model.Token, len(tokens)),
+	}
+	for _, token := range tokens {
+		r.Save(token)
+	}
+	return r
+}
+
+// Save stores token as the token of its user, replacing the previous one.
+func (r *TokenRepository) Save(token model.Token) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if previous, ok := r.byUser[token.UserId]; ok {
+		delete(r.byValue, previous.Id)
+	}
+	r.byUser[token.UserId] = token
+	r.byValue[token.Id] = token
+}
+
+func (r *TokenRepository) Get(ctx context.Context, id model.UserID) (model.Token, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	token, ok := r.byUser[id.String()]
+	if !ok {
+		return model.Token{}, errTokenNotFound
+	}
+	return token, nil
+}
+
+// FindByValue looks up the token whose value is exactly value.
+func (r *TokenRepository) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	token, ok := r.byValue[value]
+	if !ok {
+		return model.Token{}, errTokenNotFound
+	}
+	return token, nil
+}
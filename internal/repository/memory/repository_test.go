@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestLoadFixture(t *testing.T) {
+	for _, path := range []string{"testdata/fixture.yml", "testdata/fixture.json"} {
+		fixture, err := LoadFixture(path)
+
+		assert.Nil(t, err, path)
+		assert.Equal(t, []model.User{
+			{Id: 1, Name: "admin", Roles: []string{"admin"}},
+			{Id: 2, Name: "user"},
+		}, fixture.UserModels(), path)
+		assert.Equal(t, []model.Token{
+			{Id: "token_1", UserId: "1"},
+			{Id: "token_2", UserId: "2"},
+		}, fixture.TokenModels(), path)
+	}
+}
+
+func TestLoadFixture_Errors(t *testing.T) {
+	_, err := LoadFixture("testdata/missing.yml")
+	assert.NotNil(t, err)
+	_, err = LoadFixture("testdata/fixture.txt")
+	assert.NotNil(t, err)
+}
+
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(nil, model.User{Id: 1, Name: "one", Roles: []string{"admin"}}, model.User{Id: 2, Name: "two"})
+
+	user, err := repo.Get(ctx, 1)
+	assert.Nil(t, err)
+	user.Roles[0] = "changed"
+	stored, _ := repo.Get(ctx, 1)
+	assert.Equal(t, []string{"admin"}, stored.Roles)
+
+	_, err = repo.Get(ctx, 3)
+	assert.Equal(t, model.ErrUserNotFound, err)
+
+	users, err := repo.GetMany(ctx, []model.UserID{2, 3, 1})
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, model.UserID(2), users[0].Id)
+	assert.Equal(t, model.UserID(1), users[1].Id)
+}
+
+func TestTokenRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewTokenRepository(model.Token{Id: "token_1", UserId: "1"})
+	repo.Save(model.Token{Id: "token_1b", UserId: "1"})
+
+	token, err := repo.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "token_1b", token.Id)
+
+	token, err = repo.FindByValue(ctx, "token_1b")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", token.UserId)
+
+	_, err = repo.FindByValue(ctx, "token_1")
+	assert.True(t, fault.Is(err, fault.NotFound))
+	_, err = repo.Get(ctx, 2)
+	assert.True(t, fault.Is(err, fault.NotFound))
+}
+
+func TestUserRepository_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository(nil)
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(id model.UserID) {
+			defer wg.Done()
+			repo.Save(model.User{Id: id})
+			_, _ = repo.GetMany(ctx, []model.UserID{id})
+		}(model.UserID(i))
+	}
+	wg.Wait()
+
+	users, _ := repo.GetMany(ctx, []model.UserID{1, 20})
+	assert.Len(t, users, 2)
+}
+
+func TestOnboardingStore(t *testing.T) {
+	ctx, outbox := context.Background(), NewOutbox()
+	store := NewOnboardingStore(outbox)
+	_, err := store.Get(ctx, 1)
+	assert.True(t, fault.Is(err, fault.NotFound))
+
+	transition := model.OnboardingTransition{From: "created", To: "profile_completed"}
+	onboarding := model.Onboarding{UserID: 1, Step: "profile_completed", Version: 1, History: []model.OnboardingTransition{transition}}
+	events := []model.Event{{ID: "event_1", Type: model.EventOnboardingAdvanced}}
+	assert.Nil(t, store.Save(ctx, onboarding, transition, events))
+	assert.True(t, fault.Is(store.Save(ctx, onboarding, transition, []model.Event{{ID: "event_2"}}), fault.Conflict))
+	assert.Equal(t, events, outbox.Events(), "the events of a conflicting save are dropped")
+
+	onboarding.History[0].To = "changed"
+	stored, err := store.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "profile_completed", stored.Step)
+	assert.Equal(t, []model.OnboardingTransition{transition}, stored.History)
+}
+
+func TestUserRepository_CreateUpdate(t *testing.T) {
+	ctx, outbox := context.Background(), NewOutbox()
+	repo := NewUserRepository(outbox, model.User{Id: 4, Name: "four"})
+	events := func(user model.User) ([]model.Event, error) {
+		return []model.Event{{ID: user.Name, AggregateID: user.Id.String()}}, nil
+	}
+
+	created, err := repo.Create(ctx, model.User{Name: "five"}, events)
+	assert.Nil(t, err)
+	assert.Equal(t, model.UserID(5), created.Id)
+	assert.Nil(t, repo.Update(ctx, model.User{Id: 4, Name: "renamed", Roles: []string{"admin"}}, events))
+	assert.Equal(t, model.ErrUserNotFound, repo.Update(ctx, model.User{Id: 6}, events))
+	_, err = repo.Create(ctx, model.User{Name: "failed"}, func(model.User) ([]model.Event, error) {
+		return nil, fault.New(fault.Internal, "event_fail")
+	})
+	assert.NotNil(t, err)
+
+	user, _ := repo.Get(ctx, 4)
+	assert.Equal(t, &model.User{Id: 4, Name: "renamed", Roles: []string{"admin"}}, user)
+	_, err = repo.Get(ctx, 6)
+	assert.Equal(t, model.ErrUserNotFound, err, "a failed change stores nothing")
+	assert.Equal(t, []model.Event{{ID: "five", AggregateID: "5"}, {ID: "four", AggregateID: "4"}}, outbox.Events())
+	pending, _ := outbox.Pending(ctx, 1)
+	assert.Equal(t, []model.Event{{ID: "five", AggregateID: "5"}}, pending)
+}
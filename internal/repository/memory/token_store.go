@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/model"
+	"sort"
+	"sync"
+)
+
+// TokenStore keeps the tokens issued by the api and their audit trail in memory, the
+// events of the changes in its outbox. It is safe for concurrent use.
+type TokenStore struct {
+	mu      sync.RWMutex
+	records map[string]model.TokenRecord
+	byHash  map[string]string
+	audit   []model.TokenAudit
+	outbox  *Outbox
+}
+
+func NewTokenStore(outbox *Outbox) *TokenStore {
+	return &TokenStore{
+		records: map[string]model.TokenRecord{},
+		byHash:  map[string]string{},
+		outbox:  outbox,
+	}
+}
+
+func (s *TokenStore) Save(ctx context.Context, records []model.TokenRecord, audit []model.TokenAudit, events []model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		if previous, ok := s.records[record.ID]; ok {
+			delete(s.byHash, previous.Hash)
+		}
+		s.records[record.ID] = copyRecord(record)
+		s.byHash[record.Hash] = record.ID
+	}
+	s.audit = append(s.audit, audit...)
+	s.outbox.insert(events)
+	return nil
+}
+
+func (s *TokenStore) Get(ctx context.Context, id string) (model.TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[id]
+	if !ok {
+		return model.TokenRecord{}, errTokenNotFound
+	}
+	return copyRecord(record), nil
+}
+
+func (s *TokenStore) FindByHash(ctx context.Context, hash string) (model.TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.byHash[hash]
+	if !ok {
+		return model.TokenRecord{}, errTokenNotFound
+	}
+	return copyRecord(s.records[id]), nil
+}
+
+// ListByUser returns the tokens of the user, oldest first.
+func (s *TokenStore) ListByUser(ctx context.Context, userID model.UserID) ([]model.TokenRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := []model.TokenRecord{}
+	for _, record := range s.records {
+		if record.UserID == userID {
+			records = append(records, copyRecord(record))
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records, nil
+}
+
+// Audit returns the audit trail, in the order it was recorded.
+func (s *TokenStore) Audit() []model.TokenAudit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]model.TokenAudit{}, s.audit...)
+}
+
+func copyRecord(record model.TokenRecord) model.TokenRecord {
+	record.Scopes = append([]string{}, record.Scopes...)
+	if record.RevokedAt != nil {
+		revokedAt := *record.RevokedAt
+		record.RevokedAt = &revokedAt
+	}
+	return record
+}
@@ -0,0 +1,138 @@
+package onboarding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/outbox"
+	"github.com/api_base/tool/database"
+)
+
+const (
+	tableName       = "onboarding"
+	transitionTable = "onboarding_transition"
+)
+
+var (
+	errNotFound = fault.New(fault.NotFound, "onboarding_not_found")
+	errConflict = fault.New(fault.Conflict, "onboarding_changed")
+)
+
+// Repository keeps the onboarding of users in the local database: the current step in
+// the onboarding table, the history in the onboarding_transition table.
+type Repository struct {
+	database database.Database
+}
+
+func NewRepository(db database.Database) *Repository {
+	return &Repository{
+		database: db,
+	}
+}
+
+func (r *Repository) Get(ctx context.Context, userID model.UserID) (onboarding model.Onboarding, err error) {
+	query := database.NewQueryBuilder().
+		Select("user_id", "step", "version", "updated_at").
+		From(tableName).
+		Where("user_id", database.EqualThan, userID.Int64()).
+		Build()
+	ctx, span := database.StartQuerySpan(ctx, "onboarding.Repository.Get", tableName, query.String(), len(query.Args()))
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return model.Onboarding{}, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	var updatedAt sql.NullTime
+	err = conn.QueryRowContext(ctx, query.String(), query.Args()...).Scan(&onboarding.UserID, &onboarding.Step, &onboarding.Version, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Onboarding{}, errNotFound
+	}
+	if err != nil {
+		return model.Onboarding{}, err
+	}
+	if updatedAt.Valid {
+		onboarding.UpdatedAt = &updatedAt.Time
+	}
+
+	history := database.NewQueryBuilder().
+		Select("from_step", "to_step", "actor_id", "created_at").
+		From(transitionTable).
+		Where("user_id", database.EqualThan, userID.Int64()).
+		OrderBy("id").
+		Build()
+	rows, err := conn.QueryContext(ctx, history.String(), history.Args()...)
+	if err != nil {
+		return model.Onboarding{}, err
+	}
+	defer rows.Close()
+	onboarding.History = []model.OnboardingTransition{}
+	for rows.Next() {
+		var transition model.OnboardingTransition
+		var actor sql.NullInt64
+		if err := rows.Scan(&transition.From, &transition.To, &actor, &transition.At); err != nil {
+			return model.Onboarding{}, err
+		}
+		transition.Actor = model.UserID(actor.Int64)
+		onboarding.History = append(onboarding.History, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Onboarding{}, err
+	}
+	return onboarding, nil
+}
+
+// Save stores the step of onboarding if its stored version is the previous one,
+// appends transition to the history and records events in the outbox. A row already
+// inserted by a concurrent start is ignored and reported as a conflict.
+func (r *Repository) Save(ctx context.Context, onboarding model.Onboarding, transition model.OnboardingTransition, events []model.Event) (err error) {
+	statement := "INSERT IGNORE INTO " + tableName + " (user_id, step, version, updated_at) VALUES (?, ?, ?, ?)"
+	args := []interface{}{onboarding.UserID.Int64(), onboarding.Step, onboarding.Version, onboarding.UpdatedAt}
+	if onboarding.Version > 1 {
+		statement = "UPDATE " + tableName + " SET step = ?, version = ?, updated_at = ? WHERE user_id = ? AND version = ?"
+		args = []interface{}{onboarding.Step, onboarding.Version, onboarding.UpdatedAt, onboarding.UserID.Int64(), onboarding.Version - 1}
+	}
+	ctx, span := database.StartQuerySpan(ctx, "onboarding.Repository.Save", tableName, statement, len(args))
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	result, err := tx.ExecContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+		err = errConflict
+		return err
+	}
+	var actor interface{}
+	if transition.Actor.Valid() {
+		actor = transition.Actor.Int64()
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+transitionTable+" (user_id, from_step, to_step, actor_id, created_at) VALUES (?, ?, ?, ?, ?)",
+		onboarding.UserID.Int64(), transition.From, transition.To, actor, transition.At)
+	if err != nil {
+		return err
+	}
+	if err = outbox.Insert(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
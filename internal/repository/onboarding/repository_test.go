@@ -0,0 +1,77 @@
+package onboarding
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database/databasetest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	insertOnboarding = "INSERT IGNORE INTO onboarding (user_id, step, version, updated_at) VALUES (?, ?, ?, ?)"
+	updateOnboarding = "UPDATE onboarding SET step = ?, version = ?, updated_at = ? WHERE user_id = ? AND version = ?"
+	insertTransition = "INSERT INTO onboarding_transition (user_id, from_step, to_step, actor_id, created_at) VALUES (?, ?, ?, ?, ?)"
+	insertOutbox     = "INSERT INTO outbox (id, type, aggregate_id, occurred_at, payload) VALUES (?, ?, ?, ?, ?)"
+)
+
+func advance(version int) (model.Onboarding, model.OnboardingTransition, []model.Event) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	transition := model.OnboardingTransition{From: "created", To: "profile_completed", Actor: 9, At: at}
+	onboarding := model.Onboarding{UserID: 1, Step: "profile_completed", Version: version, UpdatedAt: &at}
+	events := []model.Event{{ID: "event_1", Type: model.EventOnboardingAdvanced, AggregateID: "1", OccurredAt: at, Payload: []byte(`{}`)}}
+	return onboarding, transition, events
+}
+
+func TestRepository_Save(t *testing.T) {
+	for version, statement := range map[int]string{1: insertOnboarding, 2: updateOnboarding} {
+		db := databasetest.New()
+		onboarding, transition, events := advance(version)
+
+		err := NewRepository(db).Save(context.Background(), onboarding, transition, events)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{databasetest.Begin, statement, insertTransition, insertOutbox, databasetest.Commit}, db.Queries())
+		assert.Equal(t, "event_1", db.Statements()[3].Args[0])
+	}
+}
+
+func TestRepository_Save_RollsBack(t *testing.T) {
+	for name, tt := range map[string]struct {
+		exec     func(string, []driver.Value) (driver.Result, error)
+		conflict bool
+		queries  []string
+	}{
+		"version changed": {
+			exec: func(query string, args []driver.Value) (driver.Result, error) {
+				return databasetest.Result{}, nil
+			},
+			conflict: true,
+			queries:  []string{databasetest.Begin, updateOnboarding, databasetest.Rollback},
+		},
+		"outbox insert fails": {
+			exec: func(query string, args []driver.Value) (driver.Result, error) {
+				if strings.HasPrefix(query, "INSERT INTO outbox") {
+					return nil, errors.New("outbox_full")
+				}
+				return databasetest.Result{Affected: 1}, nil
+			},
+			queries: []string{databasetest.Begin, updateOnboarding, insertTransition, insertOutbox, databasetest.Rollback},
+		},
+	} {
+		db := databasetest.New()
+		db.Exec = tt.exec
+		onboarding, transition, events := advance(2)
+
+		err := NewRepository(db).Save(context.Background(), onboarding, transition, events)
+
+		assert.NotNil(t, err, name)
+		assert.Equal(t, tt.conflict, fault.Is(err, fault.Conflict), name)
+		assert.Equal(t, tt.queries, db.Queries(), name)
+	}
+}
@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+	"time"
+)
+
+// Sink types
+const (
+	// SinkStdout writes the events to the standard output, one json per line.
+	SinkStdout = "stdout"
+	// SinkFile appends the events to Path, one json per line.
+	SinkFile = "file"
+	// SinkWebhook posts the events to the publish_event resource of the event_webhook
+	// external api of rest_client.
+	SinkWebhook = "webhook"
+)
+
+// Config enables the relay, which publishes the events of the outbox to every sink
+// every interval_seconds, batch_size events at a time. Events failing max_attempts
+// times are dead lettered.
+type Config struct {
+	Enabled         bool          `yaml:"enabled"`
+	IntervalSeconds time.Duration `yaml:"interval_seconds"`
+	BatchSize       int           `yaml:"batch_size"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+	Sinks           []SinkConfig  `yaml:"sinks"`
+}
+
+type SinkConfig struct {
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
+func (c Config) Interval() time.Duration {
+	return c.IntervalSeconds * time.Second
+}
+
+// Webhook reports whether a sink posts to the event_webhook external api.
+func (c Config) Webhook() bool {
+	for _, sink := range c.Sinks {
+		if sink.Type == SinkWebhook {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if !c.Enabled {
+		return nil
+	}
+	if c.IntervalSeconds <= 0 {
+		problems.Addf("interval_seconds", "must be a positive number of seconds")
+	}
+	if c.BatchSize <= 0 {
+		problems.Addf("batch_size", "must be a positive number")
+	}
+	if c.MaxAttempts <= 0 {
+		problems.Addf("max_attempts", "must be a positive number")
+	}
+	if len(c.Sinks) == 0 {
+		problems.Addf("sinks", "at least one sink is required")
+	}
+	for i, sink := range c.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		switch sink.Type {
+		case SinkFile:
+			if sink.Path == "" {
+				problems.Addf(path+".path", "is required")
+			}
+		case SinkStdout, SinkWebhook:
+			if sink.Path != "" {
+				problems.Addf(path+".path", "only the %s sink writes to a path", SinkFile)
+			}
+		default:
+			problems.Addf(path+".type", "unknown sink %q, expected %s, %s or %s", sink.Type, SinkStdout, SinkFile, SinkWebhook)
+		}
+	}
+	return problems.Err()
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"time"
+)
+
+// Store is the outbox read by the relay, see Repository.
+type Store interface {
+	Pending(ctx context.Context, limit int) ([]model.Event, error)
+	MarkPublished(ctx context.Context, ids []string, at time.Time) error
+	// MarkFailed returns the number of failed deliveries of the event, this one included.
+	MarkFailed(ctx context.Context, id string, cause error) (int, error)
+	// DeadLetter takes the event out of the pending ones for good.
+	DeadLetter(ctx context.Context, id string, at time.Time) error
+}
+
+// RelayResult counts the events of a relay run by outcome.
+type RelayResult struct {
+	Published int
+	// Failed is 1 when a sink rejected an event, the later events of the batch wait
+	// for the next run so events are delivered in order.
+	Failed int
+	// DeadLettered events failed too many times, they no longer hold back the later ones.
+	DeadLettered int
+}
+
+// Relay publishes the events of the outbox to the sinks. Events are marked published
+// once every sink accepted them: a failure, or a crash in between, delivers them
+// again, so delivery is at least once.
+type Relay struct {
+	store       Store
+	sinks       []Sink
+	maxAttempts int
+	logger      *logger.Logger
+	now         func() time.Time
+}
+
+func NewRelay(store Store, sinks []Sink, maxAttempts int, lg *logger.Logger) *Relay {
+	return &Relay{
+		store:       store,
+		sinks:       sinks,
+		maxAttempts: maxAttempts,
+		logger:      lg,
+		now:         time.Now,
+	}
+}
+
+// Relay publishes up to batch pending events, in the order they were recorded. An
+// event failing for the max attempts time is dead lettered and the batch goes on
+// with the next one, otherwise a failure ends the batch.
+func (r *Relay) Relay(ctx context.Context, batch int) (RelayResult, error) {
+	result := RelayResult{}
+	events, err := r.store.Pending(ctx, batch)
+	if err != nil {
+		return result, err
+	}
+	published := make([]string, 0, len(events))
+	for _, event := range events {
+		if err := r.publish(ctx, event); err != nil {
+			if !r.fail(ctx, event, err) {
+				result.Failed++
+				break
+			}
+			result.DeadLettered++
+			continue
+		}
+		published = append(published, event.ID)
+	}
+	if err := r.store.MarkPublished(ctx, published, r.now().UTC()); err != nil {
+		return result, err
+	}
+	result.Published = len(published)
+	return result, nil
+}
+
+// fail records the failed delivery of event and reports whether it was dead lettered.
+func (r *Relay) fail(ctx context.Context, event model.Event, cause error) bool {
+	attempts, err := r.store.MarkFailed(ctx, event.ID, cause)
+	if err != nil {
+		r.logger.Warn(ctx, "mark event failed fail", logger.F("event_id", event.ID), logger.Err(err))
+		return false
+	}
+	if attempts < r.maxAttempts {
+		return false
+	}
+	if err := r.store.DeadLetter(ctx, event.ID, r.now().UTC()); err != nil {
+		r.logger.Warn(ctx, "dead letter event fail", logger.F("event_id", event.ID), logger.Err(err))
+		return false
+	}
+	r.logger.Error(ctx, "event dead lettered",
+		logger.F("event_id", event.ID),
+		logger.F("event_type", string(event.Type)),
+		logger.F("attempts", attempts),
+		logger.Err(cause),
+	)
+	return true
+}
+
+func (r *Relay) publish(ctx context.Context, event model.Event) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			r.logger.Warn(ctx, "publish event fail",
+				logger.F("event_id", event.ID),
+				logger.F("event_type", string(event.Type)),
+				logger.F("sink", sink.Name()),
+				logger.Err(err),
+			)
+			return err
+		}
+	}
+	return nil
+}
+
+// Run relays every interval until ctx is done. A full batch is followed by the next
+// one right away, so a backlog drains without waiting for the interval.
+func (r *Relay) Run(ctx context.Context, interval time.Duration, batch int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := r.Relay(ctx, batch)
+		if err != nil {
+			r.logger.Error(ctx, "outbox relay fail", logger.Err(err))
+		} else if result.Published > 0 || result.Failed > 0 || result.DeadLettered > 0 {
+			r.logger.Info(ctx, "outbox relay done",
+				logger.F("published", result.Published),
+				logger.F("failed", result.Failed),
+				logger.F("dead_lettered", result.DeadLettered),
+			)
+		}
+		if err == nil && result.Failed == 0 && result.Published == batch {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/api_base/internal/domain/event"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/memory"
+	"github.com/api_base/tool/logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type sinkMock struct {
+	received []string
+	fail     map[string]int
+}
+
+func (s *sinkMock) Name() string {
+	return "mock"
+}
+
+func (s *sinkMock) Publish(ctx context.Context, e model.Event) error {
+	s.received = append(s.received, e.ID)
+	if s.fail[e.ID] > 0 {
+		s.fail[e.ID]--
+		return errors.New("sink_down")
+	}
+	return nil
+}
+
+func recordEvents(t *testing.T, n int) (*memory.Outbox, []string) {
+	outbox := memory.NewOutbox()
+	repo := memory.NewUserRepository(outbox)
+	ids := make([]string, n)
+	for i := range ids {
+		_, err := repo.Create(context.Background(), model.User{Name: "user"}, func(user model.User) ([]model.Event, error) {
+			e, err := event.User(model.EventUserCreated, user, 0, time.Now())
+			ids[i] = e.ID
+			return []model.Event{e}, err
+		})
+		assert.Nil(t, err)
+	}
+	return outbox, ids
+}
+
+func TestRelay_Relay(t *testing.T) {
+	outbox, ids := recordEvents(t, 3)
+	first, second := &sinkMock{}, &sinkMock{}
+	relay := NewRelay(outbox, []Sink{first, second}, 3, logger.Discard())
+
+	result, err := relay.Relay(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{Published: 2}, result)
+	result, err = relay.Relay(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{Published: 1}, result)
+	result, _ = relay.Relay(context.Background(), 2)
+	assert.Equal(t, RelayResult{}, result)
+
+	assert.Equal(t, ids, first.received)
+	assert.Equal(t, ids, second.received)
+}
+
+func TestRelay_Relay_FailureRedeliversInOrder(t *testing.T) {
+	outbox, ids := recordEvents(t, 3)
+	sink := &sinkMock{fail: map[string]int{}}
+	sink.fail[ids[1]] = 1
+	relay := NewRelay(outbox, []Sink{sink}, 3, logger.Discard())
+
+	result, err := relay.Relay(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{Published: 1, Failed: 1}, result)
+	assert.Equal(t, 1, outbox.Attempts(ids[1]))
+
+	result, err = relay.Relay(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{Published: 2}, result)
+	assert.Equal(t, []string{ids[0], ids[1], ids[1], ids[2]}, sink.received, "the failed event is delivered again with the same id")
+}
+
+func TestRelay_Relay_DeadLettersAfterMaxAttempts(t *testing.T) {
+	outbox, ids := recordEvents(t, 3)
+	sink := &sinkMock{fail: map[string]int{ids[1]: 100}}
+	relay := NewRelay(outbox, []Sink{sink}, 3, logger.Discard())
+
+	for attempt := 1; attempt < 3; attempt++ {
+		result, err := relay.Relay(context.Background(), 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, attempt, outbox.Attempts(ids[1]))
+	}
+	assert.False(t, outbox.DeadLettered(ids[1]))
+
+	result, err := relay.Relay(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{Published: 1, DeadLettered: 1}, result, "the event no longer holds back the later ones")
+	assert.True(t, outbox.DeadLettered(ids[1]))
+
+	result, err = relay.Relay(context.Background(), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, RelayResult{}, result)
+	assert.Equal(t, []string{ids[0], ids[1], ids[1], ids[1], ids[2]}, sink.received)
+}
+
+func TestRelay_Run(t *testing.T) {
+	outbox, ids := recordEvents(t, 5)
+	sink := &sinkMock{}
+	relay := NewRelay(outbox, []Sink{sink}, 3, logger.Discard())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		relay.Run(ctx, time.Hour, 2)
+	}()
+	assert.Eventually(t, func() bool {
+		pending, _ := outbox.Pending(context.Background(), 10)
+		return len(pending) == 0
+	}, time.Second, 5*time.Millisecond, "full batches drain without waiting for the interval")
+	cancel()
+	<-done
+	assert.Equal(t, ids, sink.received)
+}
@@ -0,0 +1,137 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database"
+	"strings"
+	"time"
+)
+
+const (
+	tableName = "outbox"
+	// maxErrorLength is the size of the last_error column.
+	maxErrorLength = 255
+)
+
+// Execer runs statements, such as the *sql.Tx of the change recording events.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Insert records events in the outbox through tx, so they are only published when
+// the transaction of the change commits.
+func Insert(ctx context.Context, tx Execer, events []model.Event) error {
+	statement := "INSERT INTO " + tableName + " (id, type, aggregate_id, occurred_at, payload) VALUES (?, ?, ?, ?, ?)"
+	for _, e := range events {
+		if _, err := tx.ExecContext(ctx, statement, e.ID, string(e.Type), e.AggregateID, e.OccurredAt, string(e.Payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Repository reads the outbox of the local database for the relay. Events are
+// inserted by the repositories of the changes, see Insert.
+type Repository struct {
+	database database.Database
+}
+
+func NewRepository(db database.Database) *Repository {
+	return &Repository{
+		database: db,
+	}
+}
+
+// Pending returns up to limit unpublished events, in the order they were recorded.
+func (r *Repository) Pending(ctx context.Context, limit int) (events []model.Event, err error) {
+	statement := "SELECT id, type, aggregate_id, occurred_at, payload FROM " + tableName +
+		" WHERE published_at IS NULL AND dead_lettered_at IS NULL ORDER BY seq LIMIT ?"
+	ctx, span := database.StartQuerySpan(ctx, "outbox.Repository.Pending", tableName, statement, 1)
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	rows, err := conn.QueryContext(ctx, statement, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events = []model.Event{}
+	for rows.Next() {
+		var e model.Event
+		var typ, payload string
+		if err := rows.Scan(&e.ID, &typ, &e.AggregateID, &e.OccurredAt, &payload); err != nil {
+			return nil, err
+		}
+		e.Type = model.EventType(typ)
+		e.Payload = []byte(payload)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkPublished records that the events of ids were delivered to every sink.
+func (r *Repository) MarkPublished(ctx context.Context, ids []string, at time.Time) (err error) {
+	if len(ids) == 0 {
+		return nil
+	}
+	statement := "UPDATE " + tableName + " SET published_at = ? WHERE id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, at)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err = r.exec(ctx, "outbox.Repository.MarkPublished", statement, args)
+	return err
+}
+
+// MarkFailed counts a failed delivery of the event of id, keeps its cause and returns
+// the number of failed deliveries. The count is read back through LAST_INSERT_ID(expr),
+// which sets the insert id of the statement, sparing a second query.
+func (r *Repository) MarkFailed(ctx context.Context, id string, cause error) (int, error) {
+	message := cause.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	statement := "UPDATE " + tableName + " SET attempts = LAST_INSERT_ID(attempts + 1), last_error = ? WHERE id = ?"
+	result, err := r.exec(ctx, "outbox.Repository.MarkFailed", statement, []interface{}{message, id})
+	if err != nil {
+		return 0, err
+	}
+	attempts, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(attempts), nil
+}
+
+// DeadLetter parks the event of id, which is no longer pending. Its attempts and
+// last_error columns tell why.
+func (r *Repository) DeadLetter(ctx context.Context, id string, at time.Time) error {
+	statement := "UPDATE " + tableName + " SET dead_lettered_at = ? WHERE id = ?"
+	_, err := r.exec(ctx, "outbox.Repository.DeadLetter", statement, []interface{}{at, id})
+	return err
+}
+
+func (r *Repository) exec(ctx context.Context, name, statement string, args []interface{}) (result sql.Result, err error) {
+	ctx, span := database.StartQuerySpan(ctx, name, tableName, statement, len(args))
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	return conn.ExecContext(ctx, statement, args...)
+}
@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database/databasetest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInsert(t *testing.T) {
+	db := databasetest.New()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	conn, err := db.GetConnection(context.Background())
+	assert.Nil(t, err)
+	defer db.CloseConnection(context.Background(), conn)
+
+	err = Insert(context.Background(), conn, []model.Event{
+		{ID: "event_1", Type: model.EventUserCreated, AggregateID: "7", OccurredAt: at, Payload: []byte(`{"id":7}`)},
+		{ID: "event_2", Type: model.EventUserUpdated, AggregateID: "7", OccurredAt: at, Payload: []byte(`{"id":7}`)},
+	})
+
+	assert.Nil(t, err)
+	statement := "INSERT INTO outbox (id, type, aggregate_id, occurred_at, payload) VALUES (?, ?, ?, ?, ?)"
+	assert.Equal(t, []databasetest.Statement{
+		{Query: statement, Args: []driver.Value{"event_1", "user.created", "7", at, `{"id":7}`}},
+		{Query: statement, Args: []driver.Value{"event_2", "user.updated", "7", at, `{"id":7}`}},
+	}, db.Statements())
+}
+
+func TestRepository_Pending(t *testing.T) {
+	db := databasetest.New()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	db.Query = func(query string, args []driver.Value) (driver.Rows, error) {
+		return databasetest.Rows([]string{"id", "type", "aggregate_id", "occurred_at", "payload"},
+			[]driver.Value{"event_1", "user.created", "7", at, `{"id":7}`},
+		), nil
+	}
+
+	events, err := NewRepository(db).Pending(context.Background(), 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Event{
+		{ID: "event_1", Type: model.EventUserCreated, AggregateID: "7", OccurredAt: at, Payload: []byte(`{"id":7}`)},
+	}, events)
+	assert.Equal(t, []databasetest.Statement{{
+		Query: "SELECT id, type, aggregate_id, occurred_at, payload FROM outbox WHERE published_at IS NULL AND dead_lettered_at IS NULL ORDER BY seq LIMIT ?",
+		Args:  []driver.Value{int64(10)},
+	}}, db.Statements())
+}
+
+func TestRepository_MarkPublished(t *testing.T) {
+	db := databasetest.New()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := NewRepository(db)
+
+	assert.Nil(t, repo.MarkPublished(context.Background(), nil, at))
+	assert.Empty(t, db.Statements(), "nothing to mark, no statement")
+
+	assert.Nil(t, repo.MarkPublished(context.Background(), []string{"event_1", "event_2"}, at))
+	assert.Equal(t, []databasetest.Statement{{
+		Query: "UPDATE outbox SET published_at = ? WHERE id IN (?, ?)",
+		Args:  []driver.Value{at, "event_1", "event_2"},
+	}}, db.Statements())
+}
+
+func TestRepository_MarkFailed(t *testing.T) {
+	db := databasetest.New()
+	db.Exec = func(query string, args []driver.Value) (driver.Result, error) {
+		return databasetest.Result{LastInsertID: 3, Affected: 1}, nil
+	}
+
+	attempts, err := NewRepository(db).MarkFailed(context.Background(), "event_1", errors.New(strings.Repeat("x", 300)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []databasetest.Statement{{
+		Query: "UPDATE outbox SET attempts = LAST_INSERT_ID(attempts + 1), last_error = ? WHERE id = ?",
+		Args:  []driver.Value{strings.Repeat("x", maxErrorLength), "event_1"},
+	}}, db.Statements())
+}
+
+func TestRepository_DeadLetter(t *testing.T) {
+	db := databasetest.New()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, NewRepository(db).DeadLetter(context.Background(), "event_1", at))
+	assert.Equal(t, []databasetest.Statement{{
+		Query: "UPDATE outbox SET dead_lettered_at = ? WHERE id = ?",
+		Args:  []driver.Value{at, "event_1"},
+	}}, db.Statements())
+}
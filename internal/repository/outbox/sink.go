@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/restclient"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// WebhookApi and PublishResource name the rest_client resource the webhook sink
+	// posts to.
+	WebhookApi      = "event_webhook"
+	PublishResource = "publish_event"
+	// IdempotencyKeyHeader carries the ID of the event posted by the webhook sink,
+	// receivers drop the events they already processed.
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// Sink delivers events outside of the api. Publish may receive an event again after
+// a failure, receivers deduplicate by the ID of the event.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// WriterSink writes the events to a writer, one json per line.
+type WriterSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(SinkStdout, os.Stdout)
+}
+
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+func (s *WriterSink) Publish(ctx context.Context, event model.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends the events to a file, synced to disk before Publish returns.
+type FileSink struct {
+	*WriterSink
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: NewWriterSink(SinkFile, file), file: file}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, event model.Event) error {
+	if err := s.WriterSink.Publish(ctx, event); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookSink posts the events to the event_webhook external api, with their ID as
+// idempotency key.
+type WebhookSink struct {
+	rc restclient.RestClient
+}
+
+func NewWebhookSink(rc restclient.RestClient) *WebhookSink {
+	return &WebhookSink{rc: rc}
+}
+
+func (s *WebhookSink) Name() string {
+	return SinkWebhook
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event model.Event) error {
+	url, err := s.rc.BuildUrl(WebhookApi, PublishResource)
+	if err != nil {
+		return err
+	}
+	return s.rc.DoPost(ctx, url, event, nil, restclient.Header{Key: IdempotencyKeyHeader, Value: event.ID})
+}
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/restclient"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testEvent = model.Event{
+	ID:          "e1",
+	Type:        model.EventUserCreated,
+	AggregateID: "1",
+	OccurredAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	Payload:     json.RawMessage(`{"id":1}`),
+}
+
+const testEventJSON = `{"id":"e1","type":"user.created","aggregate_id":"1","occurred_at":"2026-01-02T03:04:05Z","payload":{"id":1}}`
+
+func TestWriterSink(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	assert.Nil(t, NewWriterSink("buffer", out).Publish(context.Background(), testEvent))
+
+	assert.JSONEq(t, testEventJSON, out.String())
+	assert.True(t, bytes.HasSuffix(out.Bytes(), []byte("\n")))
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.jsonl")
+	sink, err := NewFileSink(path)
+	assert.Nil(t, err)
+
+	assert.Nil(t, sink.Publish(context.Background(), testEvent))
+	assert.Nil(t, sink.Publish(context.Background(), testEvent))
+	assert.Nil(t, sink.Close())
+
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, 2, bytes.Count(content, []byte("\n")))
+}
+
+func TestWebhookSink(t *testing.T) {
+	var key, body string
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		key, body = r.Header.Get(IdempotencyKeyHeader), string(raw)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	rc, _ := restclient.NewRestClient(restclient.Config{
+		TimeoutMillis: 1000,
+		ExternalApiCalls: map[string]restclient.ExternalApiCall{
+			WebhookApi: {ApiDomain: server.URL, Resources: map[string]restclient.Resource{PublishResource: {RequestUri: "/events"}}},
+		},
+	})
+	sink := NewWebhookSink(rc)
+
+	assert.NotNil(t, sink.Publish(context.Background(), testEvent))
+	status = http.StatusNoContent
+	assert.Nil(t, sink.Publish(context.Background(), testEvent))
+	assert.Equal(t, "e1", key)
+	assert.JSONEq(t, testEventJSON, body)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+	assert.Nil(t, Config{Enabled: true, IntervalSeconds: 1, BatchSize: 1, MaxAttempts: 1, Sinks: []SinkConfig{{Type: SinkStdout}}}.Validate())
+
+	err := Config{Enabled: true, Sinks: []SinkConfig{{Type: SinkFile}, {Type: "kafka"}}}.Validate()
+	assert.Contains(t, err.Error(), "interval_seconds: must be a positive number of seconds")
+	assert.Contains(t, err.Error(), "batch_size: must be a positive number")
+	assert.Contains(t, err.Error(), "max_attempts: must be a positive number")
+	assert.Contains(t, err.Error(), "sinks[0].path: is required")
+	assert.Contains(t, err.Error(), `sinks[1].type: unknown sink "kafka"`)
+}
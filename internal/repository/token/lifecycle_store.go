@@ -0,0 +1,154 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/outbox"
+	"github.com/api_base/tool/database"
+	"strings"
+)
+
+const (
+	storeTable = "api_token"
+	auditTable = "api_token_audit"
+	// scopes are stored as a comma separated list
+	scopesSeparator = ","
+)
+
+var storeColumns = []string{"id", "user_id", "hash", "scopes", "status", "created_at", "expires_at", "revoked_at"}
+
+// LifecycleStore keeps the tokens issued by the api in the local database, see token.Store of
+// the domain.
+type LifecycleStore struct {
+	database database.Database
+}
+
+func NewLifecycleStore(db database.Database) *LifecycleStore {
+	return &LifecycleStore{
+		database: db,
+	}
+}
+
+// Save upserts records, appends audit and records events in the outbox in a single
+// transaction.
+func (s *LifecycleStore) Save(ctx context.Context, records []model.TokenRecord, audit []model.TokenAudit, events []model.Event) (err error) {
+	upsert := "INSERT INTO " + storeTable + " (" + strings.Join(storeColumns, ", ") + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)" +
+		" ON DUPLICATE KEY UPDATE status = VALUES(status), revoked_at = VALUES(revoked_at)"
+	insertAudit := "INSERT INTO " + auditTable + " (token_id, user_id, event, actor_id, created_at) VALUES (?, ?, ?, ?, ?)"
+	ctx, span := database.StartQuerySpan(ctx, "token.LifecycleStore.Save", storeTable, upsert, len(records)*len(storeColumns))
+	defer func() { span.End(err) }()
+
+	conn, err := s.database.GetConnection(ctx)
+	if err != nil {
+		return fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer s.database.CloseConnection(ctx, conn)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, r := range records {
+		var revokedAt interface{}
+		if r.RevokedAt != nil {
+			revokedAt = *r.RevokedAt
+		}
+		_, err = tx.ExecContext(ctx, upsert, r.ID, r.UserID.Int64(), r.Hash, strings.Join(r.Scopes, scopesSeparator),
+			string(r.Status), r.CreatedAt, r.ExpiresAt, revokedAt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range audit {
+		var actor interface{}
+		if a.Actor.Valid() {
+			actor = a.Actor.Int64()
+		}
+		_, err = tx.ExecContext(ctx, insertAudit, a.TokenID, a.UserID.Int64(), string(a.Event), actor, a.At)
+		if err != nil {
+			return err
+		}
+	}
+	if err = outbox.Insert(ctx, tx, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *LifecycleStore) Get(ctx context.Context, id string) (model.TokenRecord, error) {
+	records, err := s.list(ctx, "token.LifecycleStore.Get", "id", id)
+	if err != nil {
+		return model.TokenRecord{}, err
+	}
+	if len(records) == 0 {
+		return model.TokenRecord{}, errTokenNotFound
+	}
+	return records[0], nil
+}
+
+func (s *LifecycleStore) FindByHash(ctx context.Context, hash string) (model.TokenRecord, error) {
+	records, err := s.list(ctx, "token.LifecycleStore.FindByHash", "hash", hash)
+	if err != nil {
+		return model.TokenRecord{}, err
+	}
+	if len(records) == 0 {
+		return model.TokenRecord{}, errTokenNotFound
+	}
+	return records[0], nil
+}
+
+// ListByUser returns the tokens of the user, oldest first.
+func (s *LifecycleStore) ListByUser(ctx context.Context, userID model.UserID) ([]model.TokenRecord, error) {
+	return s.list(ctx, "token.LifecycleStore.ListByUser", "user_id", userID.Int64())
+}
+
+func (s *LifecycleStore) list(ctx context.Context, name, column string, value interface{}) (records []model.TokenRecord, err error) {
+	query := database.NewQueryBuilder().
+		Select(storeColumns...).
+		From(storeTable).
+		Where(column, database.EqualThan, value).
+		OrderBy("created_at", "id").
+		Build()
+	ctx, span := database.StartQuerySpan(ctx, name, storeTable, query.String(), len(query.Args()))
+	defer func() { span.End(err) }()
+
+	conn, err := s.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer s.database.CloseConnection(ctx, conn)
+
+	rows, err := conn.QueryContext(ctx, query.String(), query.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records = []model.TokenRecord{}
+	for rows.Next() {
+		var r model.TokenRecord
+		var scopes, status string
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Hash, &scopes, &status, &r.CreatedAt, &r.ExpiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		if scopes != "" {
+			r.Scopes = strings.Split(scopes, scopesSeparator)
+		}
+		r.Status = model.TokenStatus(status)
+		if revokedAt.Valid {
+			r.RevokedAt = &revokedAt.Time
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
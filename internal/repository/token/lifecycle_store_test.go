@@ -0,0 +1,69 @@
+package token
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database/databasetest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	upsertRecord = "INSERT INTO api_token (id, user_id, hash, scopes, status, created_at, expires_at, revoked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)" +
+		" ON DUPLICATE KEY UPDATE status = VALUES(status), revoked_at = VALUES(revoked_at)"
+	insertAudit  = "INSERT INTO api_token_audit (token_id, user_id, event, actor_id, created_at) VALUES (?, ?, ?, ?, ?)"
+	insertOutbox = "INSERT INTO outbox (id, type, aggregate_id, occurred_at, payload) VALUES (?, ?, ?, ?, ?)"
+)
+
+func revocation() ([]model.TokenRecord, []model.TokenAudit, []model.Event) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []model.TokenRecord{{
+		ID:        "token_1",
+		UserID:    7,
+		Hash:      "hash",
+		Scopes:    []string{"users:read"},
+		Status:    model.TokenStatusRevoked,
+		CreatedAt: at,
+		ExpiresAt: at.Add(time.Hour),
+		RevokedAt: &at,
+	}}
+	audit := []model.TokenAudit{{TokenID: "token_1", UserID: 7, Event: model.TokenEventRevoked, Actor: 1, At: at}}
+	events := []model.Event{{ID: "event_1", Type: model.EventTokenRevoked, AggregateID: "token_1", OccurredAt: at, Payload: []byte(`{}`)}}
+	return records, audit, events
+}
+
+func TestLifecycleStore_Save(t *testing.T) {
+	db := databasetest.New()
+	records, audit, events := revocation()
+
+	err := NewLifecycleStore(db).Save(context.Background(), records, audit, events)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{databasetest.Begin, upsertRecord, insertAudit, insertOutbox, databasetest.Commit}, db.Queries())
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statements := db.Statements()
+	assert.Equal(t, []driver.Value{"token_1", int64(7), "hash", "users:read", "revoked", at, at.Add(time.Hour), at}, statements[1].Args)
+	assert.Equal(t, []driver.Value{"token_1", int64(7), "revoked", int64(1), at}, statements[2].Args)
+	assert.Equal(t, []driver.Value{"event_1", "token.revoked", "token_1", at, "{}"}, statements[3].Args)
+}
+
+func TestLifecycleStore_Save_OutboxFailureRollsBack(t *testing.T) {
+	db := databasetest.New()
+	db.Exec = func(query string, args []driver.Value) (driver.Result, error) {
+		if strings.HasPrefix(query, "INSERT INTO outbox") {
+			return nil, errors.New("outbox_full")
+		}
+		return databasetest.Result{Affected: 1}, nil
+	}
+	records, audit, events := revocation()
+
+	err := NewLifecycleStore(db).Save(context.Background(), records, audit, events)
+
+	assert.EqualError(t, err, "outbox_full")
+	assert.Equal(t, []string{databasetest.Begin, upsertRecord, insertAudit, insertOutbox, databasetest.Rollback}, db.Queries(),
+		"the revocation is not committed without its event")
+}
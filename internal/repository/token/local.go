@@ -0,0 +1,129 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database"
+)
+
+const (
+	// tokens are stored in the token column of the users table
+	tableName = "api"
+)
+
+var errTokenNotFound = fault.New(fault.NotFound, "token_not_found")
+
+// LocalRepository reads and stores the tokens of users in the local database.
+// An empty token column means the user has no token.
+type LocalRepository struct {
+	database database.Database
+}
+
+func NewLocalRepository(db database.Database) *LocalRepository {
+	return &LocalRepository{
+		database: db,
+	}
+}
+
+func (r *LocalRepository) Get(ctx context.Context, id model.UserID) (model.Token, error) {
+	query := database.NewQueryBuilder().
+		Select("id", "token").
+		From(tableName).
+		Where("id", database.EqualThan, id.Int64()).
+		Build()
+	return r.find(ctx, "token.LocalRepository.Get", query)
+}
+
+// FindByValue looks up the token whose value is exactly value.
+func (r *LocalRepository) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	if value == "" {
+		return model.Token{}, errTokenNotFound
+	}
+	query := database.NewQueryBuilder().
+		Select("id", "token").
+		From(tableName).
+		Where("token", database.EqualThan, value).
+		Build()
+	return r.find(ctx, "token.LocalRepository.FindByValue", query)
+}
+
+func (r *LocalRepository) find(ctx context.Context, name string, query database.Query) (token model.Token, err error) {
+	ctx, span := database.StartQuerySpan(ctx, name, tableName, query.String(), len(query.Args()))
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return model.Token{}, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	var id model.UserID
+	var value sql.NullString
+	err = conn.QueryRowContext(ctx, query.String(), query.Args()...).Scan(&id, &value)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && value.String == "") {
+		return model.Token{}, errTokenNotFound
+	}
+	if err != nil {
+		return model.Token{}, err
+	}
+	return model.Token{Id: value.String, UserId: id.String()}, nil
+}
+
+// List returns the token of every user, with an empty Id for users without token.
+func (r *LocalRepository) List(ctx context.Context) (tokens []model.Token, err error) {
+	query := database.NewQueryBuilder().
+		Select("id", "token").
+		From(tableName).
+		Build()
+	ctx, span := database.StartQuerySpan(ctx, "token.LocalRepository.List", tableName, query.String(), 0)
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	rows, err := conn.QueryContext(ctx, query.String(), query.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens = []model.Token{}
+	for rows.Next() {
+		var id model.UserID
+		var value sql.NullString
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, model.Token{Id: value.String, UserId: id.String()})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Save stores token as the token of its user. Users missing from the table are
+// left out, tokens don't create users.
+func (r *LocalRepository) Save(ctx context.Context, token model.Token) (err error) {
+	id, err := model.ParseUserID(token.UserId)
+	if err != nil {
+		return err
+	}
+	statement := "UPDATE " + tableName + " SET token = ? WHERE id = ?"
+	ctx, span := database.StartQuerySpan(ctx, "token.LocalRepository.Save", tableName, statement, 2)
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	_, err = conn.ExecContext(ctx, statement, token.Id, id.Int64())
+	return err
+}
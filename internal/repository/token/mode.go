@@ -0,0 +1,55 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository"
+)
+
+// Finder reads tokens, it is implemented by Repository and LocalRepository.
+type Finder interface {
+	Get(ctx context.Context, id model.UserID) (model.Token, error)
+	FindByValue(ctx context.Context, value string) (model.Token, error)
+}
+
+// NewModeRepository reads tokens from local and remote in the order of mode, see the
+// modes of the repository package. An empty mode is remote_only.
+func NewModeRepository(mode string, local, remote Finder) Finder {
+	switch mode {
+	case repository.ModeLocalFirst:
+		// a token missing locally may not be synchronized yet
+		return fallback{primary: local, secondary: remote, retry: func(err error) bool {
+			return fault.Is(err, fault.NotFound) || fault.Is(err, fault.Unavailable)
+		}}
+	case repository.ModeRemoteFirst:
+		// token_api is authoritative, only its unavailability falls back
+		return fallback{primary: remote, secondary: local, retry: func(err error) bool {
+			return fault.Is(err, fault.Unavailable)
+		}}
+	default:
+		return remote
+	}
+}
+
+type fallback struct {
+	primary   Finder
+	secondary Finder
+	retry     func(err error) bool
+}
+
+func (f fallback) Get(ctx context.Context, id model.UserID) (model.Token, error) {
+	token, err := f.primary.Get(ctx, id)
+	if err != nil && f.retry(err) {
+		return f.secondary.Get(ctx, id)
+	}
+	return token, err
+}
+
+func (f fallback) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	token, err := f.primary.FindByValue(ctx, value)
+	if err != nil && f.retry(err) {
+		return f.secondary.FindByValue(ctx, value)
+	}
+	return token, err
+}
@@ -0,0 +1,65 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeFinder struct {
+	token model.Token
+	err   error
+	calls int
+}
+
+func (f *fakeFinder) Get(ctx context.Context, id model.UserID) (model.Token, error) {
+	f.calls++
+	return f.token, f.err
+}
+
+func (f *fakeFinder) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	f.calls++
+	return f.token, f.err
+}
+
+func TestNewModeRepository(t *testing.T) {
+	notFound := fault.New(fault.NotFound, "token_not_found")
+	unavailable := fault.Wrap(fault.Unavailable, "database_unavailable", errors.New("down"))
+	localToken := model.Token{Id: "local", UserId: "1"}
+	remoteToken := model.Token{Id: "remote", UserId: "1"}
+	tests := []struct {
+		name        string
+		mode        string
+		localErr    error
+		remoteErr   error
+		expected    string
+		remoteCalls int
+	}{
+		{"local first hit", repository.ModeLocalFirst, nil, nil, "local", 0},
+		{"local first miss", repository.ModeLocalFirst, notFound, nil, "remote", 1},
+		{"local first unavailable", repository.ModeLocalFirst, unavailable, nil, "remote", 1},
+		{"remote first hit", repository.ModeRemoteFirst, nil, nil, "remote", 1},
+		{"remote first miss", repository.ModeRemoteFirst, nil, notFound, "", 1},
+		{"remote first unavailable", repository.ModeRemoteFirst, nil, unavailable, "local", 1},
+		{"remote only unavailable", repository.ModeRemoteOnly, nil, unavailable, "", 1},
+		{"default remote only", "", nil, nil, "remote", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := &fakeFinder{token: localToken, err: tt.localErr}
+			remote := &fakeFinder{token: remoteToken, err: tt.remoteErr}
+			if tt.remoteErr != nil {
+				remote.token = model.Token{}
+			}
+
+			token, _ := NewModeRepository(tt.mode, local, remote).Get(context.Background(), 1)
+
+			assert.Equal(t, tt.expected, token.Id)
+			assert.Equal(t, tt.remoteCalls, remote.calls)
+		})
+	}
+}
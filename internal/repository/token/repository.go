@@ -2,14 +2,24 @@ package token
 
 import (
 	"context"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
 	"github.com/api_base/internal/domain/model"
 	"github.com/api_base/tool/restclient"
+	"net/http"
+	"net/url"
 )
 
 const (
 	externalApi = "token_api"
 )
 
+func init() {
+	restclient.RegisterResource(externalApi, "get_token", 1)
+	restclient.RegisterResource(externalApi, "find_token", 1)
+	restclient.RegisterResource(externalApi, "list_tokens", 0)
+}
+
 type Repository struct {
 	rc restclient.RestClient
 }
@@ -20,15 +30,65 @@ func NewRepository(rc restclient.RestClient) *Repository {
 	}
 }
 
-func (r *Repository) Get(ctx context.Context, id string) (model.Token, error) {
+func (r *Repository) Get(ctx context.Context, id model.UserID) (model.Token, error) {
 	result := model.Token{}
-	url, err := r.rc.BuildUrl(externalApi, "get_token", id)
+	url, err := r.rc.BuildUrl(externalApi, "get_token", id.String())
 	if err != nil {
 		return result, err
 	}
 	err = r.rc.DoGet(ctx, url, &result)
 	if err != nil {
-		return result, err
+		return result, mapError(err)
 	}
 	return result, nil
 }
+
+// FindByValue looks up the token whose value is exactly value.
+func (r *Repository) FindByValue(ctx context.Context, value string) (model.Token, error) {
+	var result []model.Token
+	escaped := url.QueryEscape(value)
+	url, err := r.rc.BuildUrl(externalApi, "find_token", escaped)
+	if err != nil {
+		return model.Token{}, err
+	}
+	err = r.rc.DoGet(ctx, url, &result)
+	if err != nil {
+		return model.Token{}, mapError(err)
+	}
+	for _, token := range result {
+		if token.Id == value {
+			return token, nil
+		}
+	}
+	return model.Token{}, fault.New(fault.NotFound, "token_not_found")
+}
+
+// List returns every token known by token_api.
+func (r *Repository) List(ctx context.Context) ([]model.Token, error) {
+	var result []model.Token
+	url, err := r.rc.BuildUrl(externalApi, "list_tokens")
+	if err != nil {
+		return nil, err
+	}
+	err = r.rc.DoGet(ctx, url, &result)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return result, nil
+}
+
+// mapError translates token_api failures into the domain error taxonomy.
+func mapError(err error) error {
+	var statusErr *restclient.StatusError
+	if !errors.As(err, &statusErr) {
+		return fault.Wrap(fault.Unavailable, "token_api_unavailable", err)
+	}
+	switch statusErr.StatusCode {
+	case http.StatusNotFound:
+		return fault.Wrap(fault.NotFound, "token_not_found", err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fault.Wrap(fault.Unauthorized, "token_api_unauthorized", err)
+	default:
+		return fault.Wrap(fault.Unavailable, "token_api_unavailable", err)
+	}
+}
@@ -1 +1,105 @@
 package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/fault"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/restclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRepository(t *testing.T, handler http.HandlerFunc) *Repository {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	rc, err := restclient.NewRestClient(restclient.Config{
+		TimeoutMillis: 1000,
+		ExternalApiCalls: map[string]restclient.ExternalApiCall{
+			externalApi: {
+				ApiDomain: server.URL,
+				Resources: map[string]restclient.Resource{
+					"get_token":   {RequestUri: "/token/get/%s"},
+					"find_token":  {RequestUri: "/token?token=%s"},
+					"list_tokens": {RequestUri: "/token"},
+				},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	return NewRepository(rc)
+}
+
+func TestRepository_Get_BuildsDecimalUrl(t *testing.T) {
+	var requestedPath string
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"token":"token_65","user_id":"65"}`))
+	})
+
+	token, err := repo.Get(context.Background(), model.UserID(65))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/token/get/65", requestedPath)
+	assert.Equal(t, model.Token{Id: "token_65", UserId: "65"}, token)
+}
+
+func TestRepository_Get_NotFound(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`"Not found"`))
+	})
+
+	_, err := repo.Get(context.Background(), model.UserID(65))
+
+	assert.True(t, fault.Is(err, fault.NotFound))
+}
+
+func TestRepository_Get_Unavailable(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := repo.Get(context.Background(), model.UserID(65))
+
+	assert.True(t, fault.Is(err, fault.Unavailable))
+}
+
+func TestRepository_FindByValue(t *testing.T) {
+	var requestedToken string
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		requestedToken = r.URL.Query().Get("token")
+		_, _ = w.Write([]byte(`[{"token":"a+b&c2","user_id":"2"},{"token":"a+b&c","user_id":"1"}]`))
+	})
+
+	token, err := repo.FindByValue(context.Background(), "a+b&c")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a+b&c", requestedToken)
+	assert.Equal(t, model.Token{Id: "a+b&c", UserId: "1"}, token)
+}
+
+func TestRepository_FindByValue_NotFound(t *testing.T) {
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"token":"other","user_id":"2"}]`))
+	})
+
+	_, err := repo.FindByValue(context.Background(), "missing")
+
+	assert.True(t, fault.Is(err, fault.NotFound))
+}
+
+func TestRepository_List(t *testing.T) {
+	var requestedPath string
+	repo := newTestRepository(t, func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = w.Write([]byte(`[{"token":"token_1","user_id":"1"},{"token":"token_2","user_id":"2"}]`))
+	})
+
+	tokens, err := repo.List(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/token", requestedPath)
+	assert.Equal(t, []model.Token{{Id: "token_1", UserId: "1"}, {Id: "token_2", UserId: "2"}}, tokens)
+}
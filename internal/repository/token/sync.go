@@ -0,0 +1,113 @@
+package token
+
+import (
+	"context"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"time"
+)
+
+// Lister lists every known token.
+type Lister interface {
+	List(ctx context.Context) ([]model.Token, error)
+}
+
+// Store is the local side of the synchronization, see LocalRepository.
+type Store interface {
+	Lister
+	Save(ctx context.Context, token model.Token) error
+}
+
+// SyncResult counts the local tokens by outcome of a synchronization.
+type SyncResult struct {
+	// Updated tokens differed from token_api and were replaced.
+	Updated int
+	// Unchanged tokens matched token_api.
+	Unchanged int
+	// Removed tokens are gone from token_api and were cleared locally.
+	Removed int
+	// Failed tokens could not be saved.
+	Failed int
+}
+
+// Synchronizer copies the tokens of token_api into the local database.
+type Synchronizer struct {
+	local  Store
+	remote Lister
+	logger *logger.Logger
+}
+
+func NewSynchronizer(local Store, remote Lister, lg *logger.Logger) *Synchronizer {
+	return &Synchronizer{
+		local:  local,
+		remote: remote,
+		logger: lg,
+	}
+}
+
+// Sync reconciles the token of every local user with token_api. Local tokens of
+// users without a token in token_api are cleared, so revoked tokens stop
+// authenticating. Remote tokens of users missing locally are ignored. When a user
+// has many remote tokens the last one listed wins.
+func (s *Synchronizer) Sync(ctx context.Context) (SyncResult, error) {
+	result := SyncResult{}
+	remote, err := s.remote.List(ctx)
+	if err != nil {
+		return result, err
+	}
+	local, err := s.local.List(ctx)
+	if err != nil {
+		return result, err
+	}
+	byUser := make(map[string]model.Token, len(remote))
+	for _, token := range remote {
+		byUser[token.UserId] = token
+	}
+	for _, current := range local {
+		token, ok := byUser[current.UserId]
+		if !ok {
+			token = model.Token{UserId: current.UserId}
+		}
+		if token.Id == current.Id {
+			result.Unchanged++
+			continue
+		}
+		if err := s.local.Save(ctx, token); err != nil {
+			s.logger.Warn(ctx, "save synchronized token fail", logger.F("user_id", token.UserId), logger.Err(err))
+			result.Failed++
+			continue
+		}
+		if ok {
+			result.Updated++
+		} else {
+			result.Removed++
+		}
+	}
+	return result, nil
+}
+
+// Run synchronizes every interval until ctx is done, logging the outcome of each run.
+func (s *Synchronizer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		result, err := s.Sync(ctx)
+		if err != nil {
+			s.logger.Error(ctx, "token sync fail", logger.Err(err))
+		} else {
+			s.logger.Info(ctx, "token sync done",
+				logger.F("updated", result.Updated),
+				logger.F("unchanged", result.Unchanged),
+				logger.F("removed", result.Removed),
+				logger.F("failed", result.Failed),
+				logger.F("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
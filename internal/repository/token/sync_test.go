@@ -0,0 +1,91 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeLister struct {
+	tokens []model.Token
+	err    error
+}
+
+func (f *fakeLister) List(ctx context.Context) ([]model.Token, error) {
+	return f.tokens, f.err
+}
+
+type fakeStore struct {
+	fakeLister
+	saved   []model.Token
+	saveErr map[string]error
+}
+
+func (f *fakeStore) Save(ctx context.Context, token model.Token) error {
+	if err := f.saveErr[token.UserId]; err != nil {
+		return err
+	}
+	f.saved = append(f.saved, token)
+	return nil
+}
+
+func TestSynchronizer_Sync(t *testing.T) {
+	local := &fakeStore{
+		fakeLister: fakeLister{tokens: []model.Token{
+			{Id: "token_1", UserId: "1"},
+			{Id: "old_2", UserId: "2"},
+			{Id: "", UserId: "3"},
+			{Id: "token_4", UserId: "4"},
+			{Id: "old_5", UserId: "5"},
+		}},
+		saveErr: map[string]error{"5": errors.New("db_down")},
+	}
+	remote := &fakeLister{tokens: []model.Token{
+		{Id: "token_1", UserId: "1"},
+		{Id: "token_2", UserId: "2"},
+		{Id: "token_3", UserId: "3"},
+		{Id: "token_5", UserId: "5"},
+		{Id: "token_9", UserId: "9"},
+	}}
+
+	result, err := NewSynchronizer(local, remote, logger.Discard()).Sync(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, SyncResult{Updated: 2, Unchanged: 1, Removed: 1, Failed: 1}, result)
+	assert.Equal(t, []model.Token{
+		{Id: "token_2", UserId: "2"},
+		{Id: "token_3", UserId: "3"},
+		{Id: "", UserId: "4"},
+	}, local.saved)
+}
+
+func TestSynchronizer_Sync_MissingUpstream(t *testing.T) {
+	local := &fakeStore{
+		fakeLister: fakeLister{tokens: []model.Token{
+			{Id: "revoked_1", UserId: "1"},
+			{Id: "", UserId: "2"},
+			{Id: "revoked_3", UserId: "3"},
+		}},
+		saveErr: map[string]error{"3": errors.New("db_down")},
+	}
+	remote := &fakeLister{}
+
+	result, err := NewSynchronizer(local, remote, logger.Discard()).Sync(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, SyncResult{Unchanged: 1, Removed: 1, Failed: 1}, result)
+	assert.Equal(t, []model.Token{{Id: "", UserId: "1"}}, local.saved)
+}
+
+func TestSynchronizer_Sync_RemoteError(t *testing.T) {
+	local := &fakeStore{}
+	remote := &fakeLister{err: errors.New("token_api_unavailable")}
+
+	_, err := NewSynchronizer(local, remote, logger.Discard()).Sync(context.Background())
+
+	assert.EqualError(t, err, "token_api_unavailable")
+	assert.Empty(t, local.saved)
+}
@@ -2,8 +2,19 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"github.com/api_base/internal/domain/fault"
 	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/internal/repository/outbox"
 	"github.com/api_base/tool/database"
+	"strings"
+)
+
+const (
+	tableName = "api"
+	// roles are stored as a comma separated list
+	rolesSeparator = ","
 )
 
 type Repository struct {
@@ -16,9 +27,162 @@ func NewRepository(db database.Database) *Repository {
 	}
 }
 
-func (r *Repository) Get(ctx context.Context, id int64) (*model.User, error) {
-	modelDb := &model.User{
-		Id: id,
+func (r *Repository) Get(ctx context.Context, id model.UserID) (user *model.User, err error) {
+	query := database.NewQueryBuilder().
+		Select("id", "name", "roles").
+		From(tableName).
+		Where("id", database.EqualThan, id.Int64()).
+		Build()
+	ctx, span := database.StartQuerySpan(ctx, "user.Repository.Get", tableName, query.String(), len(query.Args()))
+	defer func() { span.End(err) }()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	modelDb := &model.User{}
+	var name, roles sql.NullString
+	err = conn.QueryRowContext(ctx, query.String(), query.Args()...).Scan(&modelDb.Id, &name, &roles)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrUserNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
+	modelDb.Name = name.String
+	modelDb.Roles = splitRoles(roles.String)
 	return modelDb, nil
 }
+
+// GetMany fetches every user whose id is in ids with a single query.
+// Ids that do not exist are simply absent from the result.
+func (r *Repository) GetMany(ctx context.Context, ids []model.UserID) ([]model.User, error) {
+	if len(ids) == 0 {
+		return []model.User{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id.Int64()
+	}
+	query := database.NewQueryBuilder().
+		Select("id", "name", "roles").
+		From(tableName).
+		Where("id", database.In, args).
+		Build()
+
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return nil, fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	rows, err := conn.QueryContext(ctx, query.String(), query.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0, len(ids))
+	for rows.Next() {
+		var user model.User
+		var name, roles sql.NullString
+		if err := rows.Scan(&user.Id, &name, &roles); err != nil {
+			return nil, err
+		}
+		user.Name = name.String
+		user.Roles = splitRoles(roles.String)
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Create inserts user and records the events built from the inserted user, with its
+// new id, in the same transaction. The legacy token column is left empty.
+func (r *Repository) Create(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (created model.User, err error) {
+	statement := "INSERT INTO " + tableName + " (name, token, roles) VALUES (?, '', ?)"
+	ctx, span := database.StartQuerySpan(ctx, "user.Repository.Create", tableName, statement, 2)
+	defer func() { span.End(err) }()
+
+	err = r.transaction(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, statement, user.Name, strings.Join(user.Roles, rolesSeparator))
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		user.Id = model.UserID(id)
+		recorded, err := events(user)
+		if err != nil {
+			return err
+		}
+		return outbox.Insert(ctx, tx, recorded)
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// Update replaces the name and roles of the user and records the events built from
+// the user before the change, in the same transaction.
+func (r *Repository) Update(ctx context.Context, user model.User, events func(model.User) ([]model.Event, error)) (err error) {
+	statement := "UPDATE " + tableName + " SET name = ?, roles = ? WHERE id = ?"
+	ctx, span := database.StartQuerySpan(ctx, "user.Repository.Update", tableName, statement, 3)
+	defer func() { span.End(err) }()
+
+	return r.transaction(ctx, func(tx *sql.Tx) error {
+		previous := model.User{}
+		var name, roles sql.NullString
+		err := tx.QueryRowContext(ctx, "SELECT id, name, roles FROM "+tableName+" WHERE id = ? FOR UPDATE", user.Id.Int64()).
+			Scan(&previous.Id, &name, &roles)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		previous.Name = name.String
+		previous.Roles = splitRoles(roles.String)
+		if _, err := tx.ExecContext(ctx, statement, user.Name, strings.Join(user.Roles, rolesSeparator), user.Id.Int64()); err != nil {
+			return err
+		}
+		recorded, err := events(previous)
+		if err != nil {
+			return err
+		}
+		return outbox.Insert(ctx, tx, recorded)
+	})
+}
+
+// transaction runs fn in a transaction, committed when fn succeeds.
+func (r *Repository) transaction(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	conn, err := r.database.GetConnection(ctx)
+	if err != nil {
+		return fault.Wrap(fault.Unavailable, "database_unavailable", err)
+	}
+	defer r.database.CloseConnection(ctx, conn)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return nil
+	}
+	return strings.Split(roles, rolesSeparator)
+}
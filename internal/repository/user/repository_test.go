@@ -1 +1,145 @@
 package user
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/api_base/internal/domain/model"
+	"github.com/api_base/tool/database/databasetest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	insertUser   = "INSERT INTO api (name, token, roles) VALUES (?, '', ?)"
+	selectUser   = "SELECT id, name, roles FROM api WHERE id = ? FOR UPDATE"
+	updateUser   = "UPDATE api SET name = ?, roles = ? WHERE id = ?"
+	insertOutbox = "INSERT INTO outbox (id, type, aggregate_id, occurred_at, payload) VALUES (?, ?, ?, ?, ?)"
+)
+
+func userEvent(user model.User) model.Event {
+	return model.Event{
+		ID:          "event_1",
+		Type:        model.EventUserUpdated,
+		AggregateID: user.Id.String(),
+		OccurredAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Payload:     []byte(`{}`),
+	}
+}
+
+func failOutbox(query string, args []driver.Value) (driver.Result, error) {
+	if strings.HasPrefix(query, "INSERT INTO outbox") {
+		return nil, errors.New("outbox_full")
+	}
+	return databasetest.Result{LastInsertID: 12, Affected: 1}, nil
+}
+
+func TestRepository_Create(t *testing.T) {
+	db := databasetest.New()
+	db.Exec = func(query string, args []driver.Value) (driver.Result, error) {
+		return databasetest.Result{LastInsertID: 12, Affected: 1}, nil
+	}
+
+	created, err := NewRepository(db).Create(context.Background(), model.User{Name: "ana", Roles: []string{"admin", "user"}},
+		func(user model.User) ([]model.Event, error) {
+			assert.Equal(t, model.UserID(12), user.Id, "events are built from the inserted user")
+			return []model.Event{userEvent(user)}, nil
+		})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.User{Id: 12, Name: "ana", Roles: []string{"admin", "user"}}, created)
+	assert.Equal(t, []string{databasetest.Begin, insertUser, insertOutbox, databasetest.Commit}, db.Queries())
+	statements := db.Statements()
+	assert.Equal(t, []driver.Value{"ana", "admin,user"}, statements[1].Args)
+	assert.Equal(t, []driver.Value{"event_1", "user.updated", "12", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "{}"}, statements[2].Args)
+}
+
+func TestRepository_Create_RollsBack(t *testing.T) {
+	for name, tt := range map[string]struct {
+		exec    func(string, []driver.Value) (driver.Result, error)
+		events  func(model.User) ([]model.Event, error)
+		err     string
+		queries []string
+	}{
+		"event builder fails": {
+			events:  func(model.User) ([]model.Event, error) { return nil, errors.New("payload_invalid") },
+			err:     "payload_invalid",
+			queries: []string{databasetest.Begin, insertUser, databasetest.Rollback},
+		},
+		"outbox insert fails": {
+			exec:    failOutbox,
+			events:  func(user model.User) ([]model.Event, error) { return []model.Event{userEvent(user)}, nil },
+			err:     "outbox_full",
+			queries: []string{databasetest.Begin, insertUser, insertOutbox, databasetest.Rollback},
+		},
+	} {
+		db := databasetest.New()
+		db.Exec = tt.exec
+
+		_, err := NewRepository(db).Create(context.Background(), model.User{Name: "ana", Roles: []string{"user"}}, tt.events)
+
+		assert.EqualError(t, err, tt.err, name)
+		assert.Equal(t, tt.queries, db.Queries(), name)
+	}
+}
+
+func TestRepository_Update(t *testing.T) {
+	db := databasetest.New()
+	db.Query = func(query string, args []driver.Value) (driver.Rows, error) {
+		return databasetest.Rows([]string{"id", "name", "roles"}, []driver.Value{int64(7), "ana", "user"}), nil
+	}
+
+	err := NewRepository(db).Update(context.Background(), model.User{Id: 7, Name: "bea", Roles: []string{"admin"}},
+		func(previous model.User) ([]model.Event, error) {
+			assert.Equal(t, model.User{Id: 7, Name: "ana", Roles: []string{"user"}}, previous, "events are built from the user before the change")
+			return []model.Event{userEvent(previous)}, nil
+		})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{databasetest.Begin, selectUser, updateUser, insertOutbox, databasetest.Commit}, db.Queries())
+	assert.Equal(t, []driver.Value{"bea", "admin", int64(7)}, db.Statements()[2].Args)
+}
+
+func TestRepository_Update_RollsBack(t *testing.T) {
+	found := func(query string, args []driver.Value) (driver.Rows, error) {
+		return databasetest.Rows([]string{"id", "name", "roles"}, []driver.Value{int64(7), "ana", "user"}), nil
+	}
+	events := func(user model.User) ([]model.Event, error) { return []model.Event{userEvent(user)}, nil }
+	for name, tt := range map[string]struct {
+		exec    func(string, []driver.Value) (driver.Result, error)
+		query   func(string, []driver.Value) (driver.Rows, error)
+		events  func(model.User) ([]model.Event, error)
+		err     error
+		queries []string
+	}{
+		"user not found": {
+			events:  events,
+			err:     model.ErrUserNotFound,
+			queries: []string{databasetest.Begin, selectUser, databasetest.Rollback},
+		},
+		"event builder fails": {
+			query:   found,
+			events:  func(model.User) ([]model.Event, error) { return nil, errors.New("payload_invalid") },
+			err:     errors.New("payload_invalid"),
+			queries: []string{databasetest.Begin, selectUser, updateUser, databasetest.Rollback},
+		},
+		"outbox insert fails": {
+			exec:    failOutbox,
+			query:   found,
+			events:  events,
+			err:     errors.New("outbox_full"),
+			queries: []string{databasetest.Begin, selectUser, updateUser, insertOutbox, databasetest.Rollback},
+		},
+	} {
+		db := databasetest.New()
+		db.Exec = tt.exec
+		db.Query = tt.query
+
+		err := NewRepository(db).Update(context.Background(), model.User{Id: 7, Name: "bea", Roles: []string{"admin"}}, tt.events)
+
+		assert.Equal(t, tt.err, err, name)
+		assert.Equal(t, tt.queries, db.Queries(), name)
+	}
+}
@@ -1,26 +1,186 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"github.com/api_base/config"
 	"github.com/api_base/internal/conectivity"
+	"github.com/api_base/internal/conectivity/response"
 	"github.com/api_base/internal/domain"
+	"github.com/api_base/internal/domain/auth"
+	"github.com/api_base/internal/domain/onboarding"
+	"github.com/api_base/internal/domain/token"
 	"github.com/api_base/internal/domain/user"
+	"github.com/api_base/tool/jwt"
+	"github.com/api_base/tool/logger"
+	"github.com/api_base/tool/ratelimit"
+	"github.com/api_base/tool/secrets"
+	"github.com/api_base/tool/server"
+	"github.com/api_base/tool/tracing"
+	"github.com/api_base/tool/validation"
+	"io/ioutil"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 func main() {
 	//Configuration
-	conf := config.NewConfig()
+	configFile := flag.String("config", "", "path to a config file replacing config/{APP_ENV}.yml")
+	flag.Parse()
+	opts, err := config.NewOptions(*configFile)
+	if err != nil {
+		log.Fatal("initialize config fail: ", err)
+	}
+	conf, err := config.Load(opts)
+	if err != nil {
+		log.Fatal("initialize config fail: ", err)
+	}
+	if args := flag.Args(); len(args) > 0 {
+		os.Exit(runCommand(conf, args))
+	}
+	lg, err := logger.New(os.Stdout, conf.Log)
+	if err != nil {
+		log.Fatal("initialize logger fail: ", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := conf.Validate(); err != nil {
+		fatal(ctx, lg, "invalid config", err)
+	}
+	response.Configure(conf.Response)
+	tracer, err := tracing.NewTracerFromConfig(conf.Tracing)
+	if err != nil {
+		fatal(ctx, lg, "initialize tracing fail", err)
+	}
+	tracing.SetTracer(tracer)
+	tracing.SetErrorHandler(func(err error) {
+		lg.Error(context.Background(), "export spans fail", logger.Err(err))
+	})
 	//Dependencies
-	ctn := domain.NewContainer(conf)
-	srv := user.NewService(ctn)
-	hdlFunc := conectivity.NewHandlerFunc(srv)
+	store := config.NewStore(conf)
+	ctn := domain.NewContainer(store, lg)
+	ctn.OnStop(tracer.Shutdown)
+	userRepo, err := ctn.UserRepository()
+	if err != nil {
+		fatal(ctx, lg, "initialize dependencies fail", err)
+	}
+	tokenRepo, err := ctn.TokenRepository()
+	if err != nil {
+		fatal(ctx, lg, "initialize dependencies fail", err)
+	}
+	srv := user.NewService(userRepo, tokenRepo, lg)
+	hdlFunc := conectivity.NewHandlerFunc(srv, lg)
+	keys, err := jwt.NewKeySet(conf.JWT)
+	if err != nil {
+		fatal(ctx, lg, "initialize jwt keys fail", err)
+	}
+	tokenSrv := token.NewService(keys)
+	tokenStore, err := ctn.TokenStore()
+	if err != nil {
+		fatal(ctx, lg, "initialize dependencies fail", err)
+	}
+	lifecycle := token.NewLifecycle(tokenStore, userRepo, conf.TokenLifecycle, lg)
+	tokenHdlFunc := conectivity.NewTokenHandlerFunc(tokenSrv, srv, lifecycle, lg)
+	onboardingStore, err := ctn.OnboardingStore()
+	if err != nil {
+		fatal(ctx, lg, "initialize dependencies fail", err)
+	}
+	policy := auth.NewPolicy(conf.Authorization)
+	onboardingSrv := onboarding.NewService(onboardingStore, userRepo, onboarding.NewStateMachine(conf.Onboarding), policy)
+	onboardingHdlFunc := conectivity.NewOnboardingHandlerFunc(onboardingSrv, lg)
+	if conf.Outbox.Enabled {
+		if _, err := ctn.Resolve(domain.OutboxRelayComponent); err != nil {
+			fatal(ctx, lg, "initialize outbox relay fail", err)
+		}
+	}
+	limiter := ratelimit.NewLimiter(conf.RateLimit, ratelimit.NewMemoryStore())
+	security := conectivity.Security{
+		Policy:      policy,
+		RateLimiter: limiter,
+	}
+	if conf.Auth.Enabled {
+		security.Authenticator = token.NewJWTAuthenticator(tokenSrv, auth.NewAuthenticator(auth.TokenFinders{lifecycle, tokenRepo}, userRepo, conf.Auth))
+	}
 	//Router
-	router := conectivity.NewRouterHandler(hdlFunc)
+	router := conectivity.NewRouterHandler(hdlFunc, tokenHdlFunc, onboardingHdlFunc, security, lg)
+	//Config reload
+	if conf.Reload.Enabled {
+		watcher := config.NewWatcher(opts, store, lg)
+		watcher.OnChange(ctn.Reconfigure)
+		watcher.OnChange(func(_, current config.Config) error {
+			response.Configure(current.Response)
+			limiter.Reconfigure(current.RateLimit)
+			level, err := logger.ParseLevel(current.Log.Level)
+			if err != nil {
+				return err
+			}
+			lg.SetLevel(level)
+			return nil
+		})
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go watcher.Watch(ctx, conf.Reload.Interval(), hangup)
+	}
 	//Start server
-	err := http.ListenAndServe(":3000", router.Handler())
+	if err = ctn.Start(ctx); err == nil {
+		err = server.NewServer(conf.Server, router.Handler()).ListenAndServe()
+	}
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout())
+	defer cancelStop()
+	if stopErr := ctn.Stop(stopCtx); stopErr != nil {
+		lg.Error(ctx, "stop dependencies fail", logger.Err(stopErr))
+	}
+	if err != nil {
+		fatal(ctx, lg, "serve fail", err)
+	}
+}
+
+// fatal logs err and exits, deferred functions don't run.
+func fatal(ctx context.Context, lg *logger.Logger, msg string, err error) {
+	lg.Error(ctx, msg, logger.Err(err))
+	os.Exit(1)
+}
+
+// runCommand runs the subcommand named by args and returns the exit code.
+func runCommand(conf config.Config, args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		return checkConfig(conf)
+	case len(args) == 3 && args[0] == "secrets" && args[1] == "encrypt":
+		return encryptSecret(conf, args[2])
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q, available commands: config check, secrets encrypt <name>\n", strings.Join(args, " "))
+	return 2
+}
+
+func checkConfig(conf config.Config) int {
+	problems, ok := conf.Validate().(validation.Problems)
+	if !ok {
+		fmt.Println("config ok")
+		return 0
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	return 1
+}
+
+// encryptSecret prints the encrypted file entry holding the value read from stdin.
+func encryptSecret(conf config.Config, name string) int {
+	plain, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "read secret fail: ", err)
+		return 1
+	}
+	sealed, err := secrets.Encrypt(os.Getenv(conf.Secrets.KeyEnvName()), name, strings.TrimRight(string(plain), "\r\n"))
 	if err != nil {
-		log.Fatal("initialize router fail: ", err)
+		fmt.Fprintln(os.Stderr, "encrypt secret fail: ", err)
+		return 1
 	}
+	fmt.Printf("%s: %s\n", name, sealed)
+	return 0
 }
@@ -6,5 +6,68 @@ CREATE TABLE IF NOT EXISTS `api_base`.`api` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
   `name` varchar(45) DEFAULT NULL,
   `token` varchar(45) NOT NULL,
+  `roles` varchar(255) NOT NULL DEFAULT 'user',
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `api_base`.`api_token` (
+  `id` char(32) NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `hash` char(64) NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `revoked_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `api_token_hash` (`hash`),
+  KEY `api_token_user_id` (`user_id`, `created_at`),
+  CONSTRAINT `api_token_user_fk` FOREIGN KEY (`user_id`) REFERENCES `api` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `api_base`.`api_token_audit` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `token_id` char(32) NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `event` varchar(16) NOT NULL,
+  `actor_id` bigint unsigned DEFAULT NULL,
+  `created_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `api_token_audit_token_id` (`token_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `api_base`.`onboarding` (
+  `user_id` bigint unsigned NOT NULL,
+  `step` varchar(64) NOT NULL,
+  `version` int unsigned NOT NULL,
+  `updated_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `onboarding_user_fk` FOREIGN KEY (`user_id`) REFERENCES `api` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `api_base`.`onboarding_transition` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `from_step` varchar(64) NOT NULL,
+  `to_step` varchar(64) NOT NULL,
+  `actor_id` bigint unsigned DEFAULT NULL,
+  `created_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `onboarding_transition_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS `api_base`.`outbox` (
+  `seq` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `id` char(32) NOT NULL,
+  `type` varchar(64) NOT NULL,
+  `aggregate_id` varchar(64) NOT NULL,
+  `occurred_at` datetime(6) NOT NULL,
+  `payload` json NOT NULL,
+  `published_at` datetime(6) DEFAULT NULL,
+  `attempts` int unsigned NOT NULL DEFAULT 0,
+  `last_error` varchar(255) DEFAULT NULL,
+  `dead_lettered_at` datetime(6) DEFAULT NULL,
+  PRIMARY KEY (`seq`),
+  UNIQUE KEY `outbox_id` (`id`),
+  KEY `outbox_pending` (`published_at`, `dead_lettered_at`, `seq`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,162 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNotRegistered = errors.New("component_not_registered")
+	ErrCycle         = errors.New("dependency_cycle")
+	ErrResolved      = errors.New("component_already_resolved")
+	ErrStarted       = errors.New("container_already_started")
+)
+
+// Provider builds a component, resolving its dependencies through r. Hooks registered
+// through r run when the container starts and stops.
+type Provider func(r Resolver) (interface{}, error)
+
+// Hook is a lifecycle function, it must return once ctx is done.
+type Hook func(ctx context.Context) error
+
+type Resolver interface {
+	Resolve(name string) (interface{}, error)
+	OnStart(hook Hook)
+	OnStop(hook Hook)
+}
+
+// Container builds components lazily from their providers, the first time they are
+// resolved, and holds them as singletons. Start runs the start hooks in registration
+// order and Stop the stop hooks in reverse order, so a component stops before its
+// dependencies.
+type Container struct {
+	mu         sync.Mutex
+	providers  map[string]Provider
+	components map[string]interface{}
+	resolving  map[string]bool
+	startHooks []Hook
+	stopHooks  []Hook
+	started    bool
+}
+
+func NewContainer() *Container {
+	return &Container{
+		providers:  map[string]Provider{},
+		components: map[string]interface{}{},
+		resolving:  map[string]bool{},
+	}
+}
+
+// Register sets the provider of name, replacing the previous one, which lets tests swap
+// implementations. It fails once name is resolved.
+func (c *Container) Register(name string, provider Provider) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.components[name]; ok {
+		return fmt.Errorf("register %s fail: %w", name, ErrResolved)
+	}
+	c.providers[name] = provider
+	return nil
+}
+
+// Resolve returns the component name, building it and its dependencies when needed.
+func (c *Container) Resolve(name string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resolve(name)
+}
+
+// Lookup returns the component name only when it is already built.
+func (c *Container) Lookup(name string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	component, ok := c.components[name]
+	return component, ok
+}
+
+func (c *Container) OnStart(hook Hook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startHooks = append(c.startHooks, hook)
+}
+
+func (c *Container) OnStop(hook Hook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopHooks = append(c.stopHooks, hook)
+}
+
+// Start runs the start hooks, stopping at the first failure. Stop must still be called
+// to release what was built.
+func (c *Container) Start(ctx context.Context) error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return ErrStarted
+	}
+	c.started = true
+	hooks := append([]Hook{}, c.startHooks...)
+	c.mu.Unlock()
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("start fail: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stop runs every stop hook, even when one fails, and returns the first error.
+func (c *Container) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	hooks := c.stopHooks
+	c.stopHooks = nil
+	c.mu.Unlock()
+	var first error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil && first == nil {
+			first = fmt.Errorf("stop fail: %w", err)
+		}
+	}
+	return first
+}
+
+// resolve must be called holding mu. Providers resolve their dependencies through
+// resolver, which calls it back without locking again.
+func (c *Container) resolve(name string) (interface{}, error) {
+	if component, ok := c.components[name]; ok {
+		return component, nil
+	}
+	provider, ok := c.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("resolve %s fail: %w", name, ErrNotRegistered)
+	}
+	if c.resolving[name] {
+		return nil, fmt.Errorf("resolve %s fail: %w", name, ErrCycle)
+	}
+	c.resolving[name] = true
+	defer delete(c.resolving, name)
+	component, err := provider(resolver{c})
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s fail: %w", name, err)
+	}
+	c.components[name] = component
+	return component, nil
+}
+
+type resolver struct {
+	c *Container
+}
+
+func (r resolver) Resolve(name string) (interface{}, error) {
+	return r.c.resolve(name)
+}
+
+func (r resolver) OnStart(hook Hook) {
+	r.c.startHooks = append(r.c.startHooks, hook)
+}
+
+func (r resolver) OnStop(hook Hook) {
+	r.c.stopHooks = append(r.c.stopHooks, hook)
+}
@@ -0,0 +1,94 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContainer_ResolveIsLazyAndSingleton(t *testing.T) {
+	c := NewContainer()
+	built := 0
+	_ = c.Register("db", func(r Resolver) (interface{}, error) {
+		built++
+		return "db", nil
+	})
+	_ = c.Register("repo", func(r Resolver) (interface{}, error) {
+		db, err := r.Resolve("db")
+		if err != nil {
+			return nil, err
+		}
+		return "repo on " + db.(string), nil
+	})
+	assert.Equal(t, 0, built)
+
+	repo, err := c.Resolve("repo")
+	assert.Nil(t, err)
+	assert.Equal(t, "repo on db", repo)
+	_, err = c.Resolve("db")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, built)
+}
+
+func TestContainer_Register(t *testing.T) {
+	c := NewContainer()
+	_ = c.Register("repo", func(r Resolver) (interface{}, error) { return "mysql", nil })
+	_ = c.Register("repo", func(r Resolver) (interface{}, error) { return "fake", nil })
+
+	repo, _ := c.Resolve("repo")
+	err := c.Register("repo", func(r Resolver) (interface{}, error) { return "other", nil })
+
+	assert.Equal(t, "fake", repo)
+	assert.True(t, errors.Is(err, ErrResolved))
+}
+
+func TestContainer_ResolveErrors(t *testing.T) {
+	c := NewContainer()
+	_ = c.Register("a", func(r Resolver) (interface{}, error) { return r.Resolve("b") })
+	_ = c.Register("b", func(r Resolver) (interface{}, error) { return r.Resolve("a") })
+	_ = c.Register("broken", func(r Resolver) (interface{}, error) { return nil, errors.New("db_down") })
+
+	_, err := c.Resolve("a")
+	assert.True(t, errors.Is(err, ErrCycle))
+	assert.EqualError(t, err, "resolve a fail: resolve b fail: resolve a fail: dependency_cycle")
+	_, err = c.Resolve("missing")
+	assert.True(t, errors.Is(err, ErrNotRegistered))
+	_, err = c.Resolve("broken")
+	assert.EqualError(t, err, "resolve broken fail: db_down")
+	_, ok := c.Lookup("broken")
+	assert.False(t, ok)
+}
+
+func TestContainer_Lifecycle(t *testing.T) {
+	c := NewContainer()
+	var calls []string
+	hook := func(name string, err error) Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	_ = c.Register("db", func(r Resolver) (interface{}, error) {
+		r.OnStart(hook("start db", nil))
+		r.OnStop(hook("stop db", nil))
+		return "db", nil
+	})
+	_ = c.Register("client", func(r Resolver) (interface{}, error) {
+		if _, err := r.Resolve("db"); err != nil {
+			return nil, err
+		}
+		r.OnStart(hook("start client", nil))
+		r.OnStop(hook("stop client", errors.New("client_busy")))
+		return "client", nil
+	})
+	_, _ = c.Resolve("client")
+
+	assert.Nil(t, c.Start(context.Background()))
+	assert.Equal(t, ErrStarted, c.Start(context.Background()))
+	err := c.Stop(context.Background())
+
+	assert.EqualError(t, err, "stop fail: client_busy")
+	assert.Equal(t, []string{"start db", "start client", "stop client", "stop db"}, calls)
+	assert.Nil(t, c.Stop(context.Background()))
+}
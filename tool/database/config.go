@@ -1,6 +1,10 @@
 package database
 
-import "time"
+import (
+	"database/sql"
+	"github.com/api_base/tool/validation"
+	"time"
+)
 
 // default values
 const (
@@ -22,3 +26,76 @@ type Config struct {
 	MaxIdleConns         int            `yaml:"max_idle_connections_per_host"`
 	MaxOpenConns         int            `yaml:"max_open_connections"`
 }
+
+// sameConnection reports whether both configs open the same connections, ignoring the
+// pool settings which can change on a running pool.
+func (c Config) sameConnection(other Config) bool {
+	return c.Driver == other.Driver && c.DbHost == other.DbHost && c.DbName == other.DbName &&
+		c.DbUsername == other.DbUsername && c.DbPassword == other.DbPassword &&
+		equalTimeout(c.ConnReadTimeout, other.ConnReadTimeout) &&
+		equalTimeout(c.ConnWriteTimeout, other.ConnWriteTimeout) &&
+		equalTimeout(c.ConnTimeout, other.ConnTimeout)
+}
+
+func equalTimeout(a, b *time.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Validate reports every problem of the config, the driver must be registered in database/sql.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if c.Driver == "" {
+		problems.Addf("driver", "is required")
+	} else if !registeredDriver(c.Driver) {
+		problems.Addf("driver", "unknown driver %q, registered drivers: %v", c.Driver, sql.Drivers())
+	}
+	if c.DbHost == "" {
+		problems.Addf("host", "is required")
+	}
+	if c.DbName == "" {
+		problems.Addf("name", "is required")
+	}
+	if c.DbUsername == "" {
+		problems.Addf("user", "is required")
+	}
+	if c.ConnMaxLifetime < 0 {
+		problems.Addf("connection_max_life_time_seconds", "must not be negative")
+	}
+	timeouts := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"connection_read_timeout", c.ConnReadTimeout},
+		{"connection_write_timeout", c.ConnWriteTimeout},
+		{"connection_timeout", c.ConnTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value != nil && *timeout.value <= 0 {
+			problems.Addf(timeout.name, "must be a positive duration")
+		}
+	}
+	if c.MaxConnectionRetries < 0 {
+		problems.Addf("max_connection_retries", "must not be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		problems.Addf("max_idle_connections_per_host", "must not be negative")
+	}
+	if c.MaxOpenConns < 0 {
+		problems.Addf("max_open_connections", "must not be negative")
+	} else if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		problems.Addf("max_idle_connections_per_host", "must not exceed max_open_connections")
+	}
+	return problems.Err()
+}
+
+func registeredDriver(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
@@ -3,15 +3,20 @@ package database
 import (
 	"context"
 	"database/sql"
+	"sync"
 )
 
 type Database interface {
 	GetConnection(ctx context.Context) (*sql.Conn, error)
 	CloseConnection(ctx context.Context, dbc *sql.Conn) error
+	Reconfigure(config Config) error
+	Stats() sql.DBStats
 	Close() error
 }
 
 type database struct {
+	mu                   sync.RWMutex
 	db                   *sql.DB
+	config               Config
 	maxConnectionRetries int
 }
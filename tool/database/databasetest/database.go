@@ -0,0 +1,202 @@
+// Package databasetest runs the repositories of the local database against a fake
+// driver, which records the statements it receives and answers them from the test.
+package databasetest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/api_base/tool/database"
+	"io"
+	"sync"
+)
+
+// Transaction statements, recorded among the others.
+const (
+	Begin    = "BEGIN"
+	Commit   = "COMMIT"
+	Rollback = "ROLLBACK"
+)
+
+// Statement is a statement received by the driver.
+type Statement struct {
+	Query string
+	Args  []driver.Value
+}
+
+// Database is a database.Database over the fake driver. Exec and Query answer the
+// statements; when nil, every exec affects one row and every query returns no rows.
+type Database struct {
+	Exec  func(query string, args []driver.Value) (driver.Result, error)
+	Query func(query string, args []driver.Value) (driver.Rows, error)
+
+	db         *sql.DB
+	mu         sync.Mutex
+	statements []Statement
+}
+
+func New() *Database {
+	d := &Database{}
+	d.db = sql.OpenDB(connector{database: d})
+	return d
+}
+
+// Statements returns the statements received so far, in order.
+func (d *Database) Statements() []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Statement{}, d.statements...)
+}
+
+// Queries returns the queries of Statements.
+func (d *Database) Queries() []string {
+	statements := d.Statements()
+	queries := make([]string, len(statements))
+	for i, s := range statements {
+		queries[i] = s.Query
+	}
+	return queries
+}
+
+func (d *Database) GetConnection(ctx context.Context) (*sql.Conn, error) {
+	return d.db.Conn(ctx)
+}
+
+func (d *Database) CloseConnection(ctx context.Context, dbc *sql.Conn) error {
+	return dbc.Close()
+}
+
+func (d *Database) Reconfigure(config database.Config) error {
+	return nil
+}
+
+func (d *Database) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+func (d *Database) record(query string, args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, Statement{Query: query, Args: values})
+	return values
+}
+
+// Result is the result of an exec.
+type Result struct {
+	LastInsertID int64
+	Affected     int64
+}
+
+func (r Result) LastInsertId() (int64, error) {
+	return r.LastInsertID, nil
+}
+
+func (r Result) RowsAffected() (int64, error) {
+	return r.Affected, nil
+}
+
+// Rows returns the rows of a query with columns, one slice of values per row.
+func Rows(columns []string, values ...[]driver.Value) driver.Rows {
+	return &rows{columns: columns, values: values}
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+type connector struct {
+	database *Database
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{database: c.database}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("databasetest_open_unsupported")
+}
+
+// conn answers statements directly, it never prepares them.
+type conn struct {
+	database *Database
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("databasetest_prepare_unsupported")
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.database.record(Begin, nil)
+	return tx{database: c.database}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := c.database.record(query, args)
+	if c.database.Exec == nil {
+		return Result{Affected: 1}, nil
+	}
+	return c.database.Exec(query, values)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := c.database.record(query, args)
+	if c.database.Query == nil {
+		return Rows(nil), nil
+	}
+	return c.database.Query(query, values)
+}
+
+type tx struct {
+	database *Database
+}
+
+func (t tx) Commit() error {
+	t.database.record(Commit, nil)
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.database.record(Rollback, nil)
+	return nil
+}
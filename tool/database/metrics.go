@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"github.com/api_base/tool/metrics"
+)
+
+// RegisterMetrics exposes the sql.DBStats of db in registry. The stats are read on every
+// scrape, so they follow the pool across reconnections.
+func RegisterMetrics(registry *metrics.Registry, db Database) {
+	gauges := []struct {
+		name, help string
+		value      func(sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Established connections, in use and idle.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Connections currently in use.", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		value := g.value
+		registry.GaugeFunc(g.name, g.help, func() float64 { return value(db.Stats()) })
+	}
+	counters := []struct {
+		name, help string
+		value      func(sql.DBStats) float64
+	}{
+		{"db_wait_count_total", "Connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Connections closed due to max_idle_connections_per_host.", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_lifetime_closed_total", "Connections closed due to connection_max_life_time_seconds.", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		value := c.value
+		registry.CounterFunc(c.name, c.help, func() float64 { return value(db.Stats()) })
+	}
+}
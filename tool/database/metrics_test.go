@@ -0,0 +1,40 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/api_base/tool/metrics"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type statsDatabase struct {
+	Database
+	stats sql.DBStats
+}
+
+func (s *statsDatabase) Stats() sql.DBStats {
+	return s.stats
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	db := &statsDatabase{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 4, InUse: 1, Idle: 3, WaitCount: 2, WaitDuration: 1500 * time.Millisecond}}
+
+	RegisterMetrics(registry, db)
+	db.stats.InUse = 2
+
+	out := bytes.Buffer{}
+	assert.Nil(t, registry.Write(&out))
+	for _, line := range []string{
+		"db_max_open_connections 10\n",
+		"db_open_connections 4\n",
+		"db_in_use_connections 2\n",
+		"db_idle_connections 3\n",
+		"# TYPE db_wait_count_total counter\ndb_wait_count_total 2\n",
+		"db_wait_duration_seconds_total 1.5\n",
+	} {
+		assert.Contains(t, out.String(), line)
+	}
+}
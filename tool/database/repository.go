@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,8 +13,20 @@ import (
 const ConnectionFormat = "%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True"
 
 func NewRepository(config Config) (*database, error) {
+	db, err := open(config)
+	if err != nil {
+		return nil, err
+	}
+	return &database{
+		db:                   db,
+		config:               config,
+		maxConnectionRetries: maxConnectionRetries(config),
+	}, nil
+}
+
+func open(config Config) (*sql.DB, error) {
 	// connection
-	connectionString := fmt.Sprintf(ConnectionFormat, config.DbUsername, getEnv(config.DbPassword), getEnv(config.DbHost), config.DbName)
+	connectionString := fmt.Sprintf(ConnectionFormat, config.DbUsername, config.DbPassword, config.DbHost, config.DbName)
 	// if config has ConnReadTimeout set, appends readTimeout param
 	if config.ConnReadTimeout != nil {
 		connectionString = fmt.Sprintf("%s&readTimeout=%s", connectionString, config.ConnReadTimeout.String())
@@ -38,35 +49,62 @@ func NewRepository(config Config) (*database, error) {
 	if err != nil {
 		return nil, err
 	}
-	// set connection pool configs
+	configurePool(db, config)
+	return db, nil
+}
+
+func configurePool(db *sql.DB, config Config) {
 	db.SetMaxIdleConns(config.MaxIdleConns)
 	db.SetMaxOpenConns(config.MaxOpenConns)
 	db.SetConnMaxLifetime(config.ConnMaxLifetime * time.Second)
-	retries := defaultMaxConnectionRetries
+}
+
+func maxConnectionRetries(config Config) int {
 	if config.MaxConnectionRetries > 0 {
-		retries = config.MaxConnectionRetries
+		return config.MaxConnectionRetries
 	}
-	return &database{
-		db:                   db,
-		maxConnectionRetries: retries,
-	}, nil
+	return defaultMaxConnectionRetries
 }
 
-func getEnv(name string) string {
-	value := os.Getenv(name)
-	if value != "" {
-		return value
+// Reconfigure applies config to the running pool. When the connection settings change a
+// new pool is opened and tested before replacing the current one, which is then closed
+// once its in-flight queries finish; on error the current pool is kept.
+func (d *database) Reconfigure(config Config) error {
+	d.mu.Lock()
+	current := d.config
+	d.mu.Unlock()
+	if !config.sameConnection(current) {
+		db, err := open(config)
+		if err != nil {
+			return err
+		}
+		d.mu.Lock()
+		old := d.db
+		d.db, d.config, d.maxConnectionRetries = db, config, maxConnectionRetries(config)
+		d.mu.Unlock()
+		return old.Close()
 	}
-	return name
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	configurePool(d.db, config)
+	d.config, d.maxConnectionRetries = config, maxConnectionRetries(config)
+	return nil
+}
+
+func (d *database) pool() (*sql.DB, int) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.db, d.maxConnectionRetries
 }
 
 func (d *database) GetConnection(ctx context.Context) (*sql.Conn, error) {
 	var err error
 	var conn *sql.Conn
 
-	for retry := 0; retry < d.maxConnectionRetries; retry++ {
+	db, retries := d.pool()
+	for retry := 0; retry < retries; retry++ {
 		// Obtain the connection
-		conn, err = d.db.Conn(ctx)
+		conn, err = db.Conn(ctx)
 		if err != nil {
 			continue
 		}
@@ -81,10 +119,11 @@ func (d *database) GetConnection(ctx context.Context) (*sql.Conn, error) {
 
 // TestConnection tests the given connection
 func (d *database) TestConnection(ctx context.Context) error {
+	db, _ := d.pool()
 	if ctx != nil {
-		return d.db.PingContext(ctx)
+		return db.PingContext(ctx)
 	}
-	return d.db.Ping()
+	return db.Ping()
 }
 
 // CloseConnection closes a given connection
@@ -103,6 +142,13 @@ func (d *database) CloseConnection(ctx context.Context, dbc *sql.Conn) error {
 	return nil
 }
 
+// Stats returns the statistics of the current pool.
+func (d *database) Stats() sql.DBStats {
+	db, _ := d.pool()
+	return db.Stats()
+}
+
 func (d *database) Close() error {
-	return d.db.Close()
+	db, _ := d.pool()
+	return db.Close()
 }
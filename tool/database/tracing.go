@@ -0,0 +1,19 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/api_base/tool/tracing"
+)
+
+// StartQuerySpan starts the span of a statement run on table. Only the statement is
+// recorded, its placeholders keep the argument values, tokens among them, out of the
+// trace.
+func StartQuerySpan(ctx context.Context, name, table, statement string, args int) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, name, tracing.KindClient)
+	span.SetAttribute("db.system", "mysql")
+	span.SetAttribute("db.sql.table", table)
+	span.SetAttribute("db.statement", statement)
+	span.SetAttribute("db.args", fmt.Sprintf("[%d redacted]", args))
+	return ctx, span
+}
@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+	"time"
+)
+
+// default values
+const (
+	defaultTTL = 15 * time.Minute
+)
+
+// Config jwt issuance and verification config
+type Config struct {
+	Issuer        string        `yaml:"issuer"`
+	Audience      string        `yaml:"audience"`
+	TTLSeconds    time.Duration `yaml:"ttl_seconds"`
+	LeewaySeconds time.Duration `yaml:"leeway_seconds"`
+	SigningKid    string        `yaml:"signing_kid"`
+	Keys          []KeyConfig   `yaml:"keys"`
+}
+
+// KeyConfig describes a key of the key set. HS256 keys use Secret, RS256 and ES256 keys
+// are read from PEM files. Keys with only a public key file verify tokens signed before
+// a rotation but can't sign new ones.
+type KeyConfig struct {
+	Kid            string `yaml:"kid"`
+	Alg            string `yaml:"alg"`
+	Secret         string `yaml:"secret"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// TTL returns the lifetime of issued tokens.
+func (c Config) TTL() time.Duration {
+	if c.TTLSeconds > 0 {
+		return c.TTLSeconds * time.Second
+	}
+	return defaultTTL
+}
+
+// Leeway returns the clock skew tolerated when validating exp, nbf and iat.
+func (c Config) Leeway() time.Duration {
+	return c.LeewaySeconds * time.Second
+}
+
+// Validate reports every problem of the config, keys are loaded to check their files.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if c.TTLSeconds < 0 {
+		problems.Addf("ttl_seconds", "must not be negative")
+	}
+	if c.LeewaySeconds < 0 {
+		problems.Addf("leeway_seconds", "must not be negative")
+	}
+	keys := map[string]*Key{}
+	for i, kc := range c.Keys {
+		path := fmt.Sprintf("keys[%d]", i)
+		if kc.Kid == "" {
+			problems.Addf(path+".kid", "is required")
+			continue
+		}
+		if _, exist := keys[kc.Kid]; exist {
+			problems.Addf(path+".kid", "duplicated kid %q", kc.Kid)
+			continue
+		}
+		key, err := NewKey(kc)
+		if err != nil {
+			problems.Addf(path, "%v", err)
+			continue
+		}
+		keys[kc.Kid] = key
+	}
+	if c.SigningKid != "" {
+		if key, exist := keys[c.SigningKid]; !exist {
+			problems.Addf("signing_kid", "unknown kid %q", c.SigningKid)
+		} else if !key.CanSign() {
+			problems.Addf("signing_kid", "key %q has no private key", c.SigningKid)
+		}
+	}
+	return problems.Err()
+}
@@ -0,0 +1,53 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"math/big"
+	"sort"
+)
+
+// JWKS is the RFC 7517 JSON Web Key Set publishing the public keys of a KeySet.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys of the set, sorted by kid. HS256 keys are symmetric
+// and therefore never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA", Kid: key.Kid, Alg: key.Alg, Use: "sig",
+				N: encoding.EncodeToString(pub.N.Bytes()),
+				E: encoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			x, y := make([]byte, 32), make([]byte, 32)
+			pub.X.FillBytes(x)
+			pub.Y.FillBytes(y)
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "EC", Kid: key.Kid, Alg: key.Alg, Use: "sig", Crv: "P-256",
+				X: encoding.EncodeToString(x),
+				Y: encoding.EncodeToString(y),
+			})
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
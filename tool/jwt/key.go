@@ -0,0 +1,178 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+var (
+	ErrUnsupportedAlg = errors.New("jwt_unsupported_alg")
+	ErrInvalidKey     = errors.New("jwt_invalid_key")
+	ErrCannotSign     = errors.New("jwt_key_cannot_sign")
+)
+
+// Key signs and verifies tokens with one algorithm.
+type Key struct {
+	Kid     string
+	Alg     string
+	secret  []byte
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// NewKey loads the key described by config.
+func NewKey(config KeyConfig) (*Key, error) {
+	key := &Key{Kid: config.Kid, Alg: config.Alg}
+	switch config.Alg {
+	case HS256:
+		if config.Secret == "" {
+			return nil, fmt.Errorf("%w: %s requires a secret", ErrInvalidKey, config.Kid)
+		}
+		key.secret = []byte(config.Secret)
+		return key, nil
+	case RS256, ES256:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, config.Alg)
+	}
+
+	if config.PrivateKeyFile != "" {
+		block, err := readPEM(config.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		private, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKey, config.Kid, err)
+		}
+		key.private = private
+		key.public = private.Public()
+	} else if config.PublicKeyFile != "" {
+		block, err := readPEM(config.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKey, config.Kid, err)
+		}
+		key.public = public
+	} else {
+		return nil, fmt.Errorf("%w: %s requires a private_key_file or a public_key_file", ErrInvalidKey, config.Kid)
+	}
+	if err := key.checkType(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (k *Key) checkType() error {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if k.Alg == RS256 {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if k.Alg == ES256 && pub.Curve == elliptic.P256() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s key type doesn't match %s", ErrInvalidKey, k.Kid, k.Alg)
+}
+
+// CanSign reports whether the key holds the secret or private material to sign tokens.
+func (k *Key) CanSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	if !k.CanSign() {
+		return nil, ErrCannotSign
+	}
+	digest := sha256.Sum256(input)
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		return rsa.SignPKCS1v15(rand.Reader, k.private.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, k.private.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed size R || S encoding instead of ASN.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+	return nil, ErrUnsupportedAlg
+}
+
+func (k *Key) verify(input, sig []byte) bool {
+	digest := sha256.Sum256(input)
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case RS256:
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	case ES256:
+		if len(sig) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k.public.(*ecdsa.PublicKey), digest[:], r, s)
+	}
+	return false
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s has no PEM block", ErrInvalidKey, path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
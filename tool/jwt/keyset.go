@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("jwt_malformed")
+	ErrUnknownKey       = errors.New("jwt_unknown_key")
+	ErrInvalidSignature = errors.New("jwt_invalid_signature")
+	ErrExpired          = errors.New("jwt_expired")
+	ErrNotYetValid      = errors.New("jwt_not_yet_valid")
+	ErrInvalidIssuer    = errors.New("jwt_invalid_issuer")
+	ErrInvalidAudience  = errors.New("jwt_invalid_audience")
+)
+
+var encoding = base64.RawURLEncoding
+
+// RegisteredClaims are the RFC 7519 claims validated by Verify.
+type RegisteredClaims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ID        string `json:"jti,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// KeySet signs tokens with its signing key and verifies tokens signed by any of its keys,
+// selected by the kid header. Keeping the previous keys in the set after changing the
+// signing kid lets tokens issued before a rotation stay valid until they expire.
+type KeySet struct {
+	config  Config
+	keys    map[string]*Key
+	signing *Key
+	now     func() time.Time
+}
+
+// NewKeySet loads every key of config.
+func NewKeySet(config Config) (*KeySet, error) {
+	ks := &KeySet{config: config, keys: map[string]*Key{}, now: time.Now}
+	for _, kc := range config.Keys {
+		if _, exist := ks.keys[kc.Kid]; exist {
+			return nil, fmt.Errorf("%w: duplicated kid %q", ErrInvalidKey, kc.Kid)
+		}
+		key, err := NewKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[kc.Kid] = key
+	}
+	if config.SigningKid != "" {
+		signing, exist := ks.keys[config.SigningKid]
+		if !exist || !signing.CanSign() {
+			return nil, fmt.Errorf("%w: signing kid %q", ErrCannotSign, config.SigningKid)
+		}
+		ks.signing = signing
+	}
+	return ks, nil
+}
+
+// Config returns the config the key set was created with.
+func (ks *KeySet) Config() Config {
+	return ks.config
+}
+
+// Sign serializes claims as the payload of a token signed with the signing key.
+func (ks *KeySet) Sign(claims interface{}) (string, error) {
+	if ks.signing == nil {
+		return "", ErrCannotSign
+	}
+	h, err := json.Marshal(header{Alg: ks.signing.Alg, Typ: "JWT", Kid: ks.signing.Kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := encoding.EncodeToString(h) + "." + encoding.EncodeToString(payload)
+	sig, err := ks.signing.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + encoding.EncodeToString(sig), nil
+}
+
+// Verify checks the signature and the registered claims of token and decodes its
+// payload into claims. exp and nbf are checked with the configured leeway, iss and
+// aud must match the config when it sets them.
+func (ks *KeySet) Verify(token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+	h := header{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return err
+	}
+	key, err := ks.keyFor(h)
+	if err != nil {
+		return err
+	}
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return ErrInvalidSignature
+	}
+	registered := struct {
+		RegisteredClaims
+		Audience json.RawMessage `json:"aud,omitempty"`
+	}{}
+	if err := decodeSegment(parts[1], &registered); err != nil {
+		return err
+	}
+	if err := ks.validate(registered.RegisteredClaims, registered.Audience); err != nil {
+		return err
+	}
+	return decodeSegment(parts[1], claims)
+}
+
+func (ks *KeySet) keyFor(h header) (*Key, error) {
+	var key *Key
+	if h.Kid != "" {
+		key = ks.keys[h.Kid]
+	} else if len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	// the algorithm is bound to the key, never taken from the token: this rejects
+	// "none" and algorithm confusion attacks.
+	if h.Alg != key.Alg {
+		return nil, ErrInvalidSignature
+	}
+	return key, nil
+}
+
+func (ks *KeySet) validate(claims RegisteredClaims, audience json.RawMessage) error {
+	now := ks.now()
+	leeway := ks.config.Leeway()
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return ErrExpired
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrNotYetValid
+	}
+	if claims.IssuedAt != 0 && now.Add(leeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return ErrNotYetValid
+	}
+	if ks.config.Issuer != "" && claims.Issuer != ks.config.Issuer {
+		return ErrInvalidIssuer
+	}
+	if ks.config.Audience != "" && !containsAudience(audience, ks.config.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// containsAudience accepts both forms of the aud claim: a string or a list of strings.
+func containsAudience(raw json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == audience
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		for _, aud := range list {
+			if aud == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
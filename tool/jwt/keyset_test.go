@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	RegisteredClaims
+	Name string `json:"name"`
+}
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	path := filepath.Join(t.TempDir(), strings.ReplaceAll(typ, " ", "_")+".pem")
+	assert.Nil(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+func rsaKeyFiles(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	return writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)), writePEM(t, "PUBLIC KEY", pub)
+}
+
+func ecKeyFile(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	return writePEM(t, "PRIVATE KEY", der)
+}
+
+func TestKeySet_SignVerify(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+	keys := []KeyConfig{
+		{Kid: "hs", Alg: HS256, Secret: "super-secret"},
+		{Kid: "rs", Alg: RS256, PrivateKeyFile: rsaPrivate},
+		{Kid: "es", Alg: ES256, PrivateKeyFile: ecKeyFile(t)},
+	}
+	for _, kc := range keys {
+		ks, err := NewKeySet(Config{Issuer: "api_base", Audience: "clients", SigningKid: kc.Kid, Keys: keys})
+		assert.Nil(t, err)
+		now := time.Now()
+		claims := testClaims{RegisteredClaims{Issuer: "api_base", Audience: "clients", Subject: "7", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}, "seven"}
+
+		token, err := ks.Sign(claims)
+		assert.Nil(t, err, kc.Kid)
+
+		decoded := testClaims{}
+		assert.Nil(t, ks.Verify(token, &decoded), kc.Kid)
+		assert.Equal(t, claims, decoded, kc.Kid)
+	}
+}
+
+func TestKeySet_Verify_ClockSkew(t *testing.T) {
+	ks, err := NewKeySet(Config{LeewaySeconds: 30, SigningKid: "hs", Keys: []KeyConfig{{Kid: "hs", Alg: HS256, Secret: "s"}}})
+	assert.Nil(t, err)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+
+	expiredWithinLeeway, _ := ks.Sign(RegisteredClaims{ExpiresAt: now.Add(-20 * time.Second).Unix()})
+	expired, _ := ks.Sign(RegisteredClaims{ExpiresAt: now.Add(-40 * time.Second).Unix()})
+	issuedAhead, _ := ks.Sign(RegisteredClaims{IssuedAt: now.Add(20 * time.Second).Unix()})
+	notYetValid, _ := ks.Sign(RegisteredClaims{NotBefore: now.Add(time.Minute).Unix()})
+
+	assert.Nil(t, ks.Verify(expiredWithinLeeway, &RegisteredClaims{}))
+	assert.Equal(t, ErrExpired, ks.Verify(expired, &RegisteredClaims{}))
+	assert.Nil(t, ks.Verify(issuedAhead, &RegisteredClaims{}))
+	assert.Equal(t, ErrNotYetValid, ks.Verify(notYetValid, &RegisteredClaims{}))
+}
+
+func TestKeySet_Verify_Rejections(t *testing.T) {
+	ks, err := NewKeySet(Config{Issuer: "api_base", Audience: "clients", SigningKid: "hs", Keys: []KeyConfig{{Kid: "hs", Alg: HS256, Secret: "s"}}})
+	assert.Nil(t, err)
+	other, err := NewKeySet(Config{SigningKid: "hs", Keys: []KeyConfig{{Kid: "hs", Alg: HS256, Secret: "other"}}})
+	assert.Nil(t, err)
+	valid := RegisteredClaims{Issuer: "api_base", Audience: "clients"}
+
+	forged, _ := other.Sign(valid)
+	wrongIssuer, _ := ks.Sign(RegisteredClaims{Issuer: "evil", Audience: "clients"})
+	wrongAudience, _ := ks.Sign(RegisteredClaims{Issuer: "api_base", Audience: "others"})
+	token, _ := ks.Sign(valid)
+	parts := strings.Split(token, ".")
+	none := encoding.EncodeToString([]byte(`{"alg":"none","kid":"hs"}`)) + "." + parts[1] + "."
+	unknownKid := encoding.EncodeToString([]byte(`{"alg":"HS256","kid":"nope"}`)) + "." + parts[1] + "." + parts[2]
+
+	assert.Equal(t, ErrInvalidSignature, ks.Verify(forged, &RegisteredClaims{}))
+	assert.Equal(t, ErrInvalidIssuer, ks.Verify(wrongIssuer, &RegisteredClaims{}))
+	assert.Equal(t, ErrInvalidAudience, ks.Verify(wrongAudience, &RegisteredClaims{}))
+	assert.Equal(t, ErrInvalidSignature, ks.Verify(none, &RegisteredClaims{}))
+	assert.Equal(t, ErrUnknownKey, ks.Verify(unknownKid, &RegisteredClaims{}))
+	assert.Equal(t, ErrMalformed, ks.Verify("not-a-token", &RegisteredClaims{}))
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyFiles(t)
+	before, err := NewKeySet(Config{SigningKid: "2021-09", Keys: []KeyConfig{{Kid: "2021-09", Alg: RS256, PrivateKeyFile: rsaPrivate}}})
+	assert.Nil(t, err)
+	issuedBefore, err := before.Sign(RegisteredClaims{Subject: "1"})
+	assert.Nil(t, err)
+
+	after, err := NewKeySet(Config{SigningKid: "2021-10", Keys: []KeyConfig{
+		{Kid: "2021-09", Alg: RS256, PublicKeyFile: rsaPublic},
+		{Kid: "2021-10", Alg: ES256, PrivateKeyFile: ecKeyFile(t)},
+	}})
+	assert.Nil(t, err)
+	issuedAfter, err := after.Sign(RegisteredClaims{Subject: "1"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, after.Verify(issuedBefore, &RegisteredClaims{}))
+	assert.Nil(t, after.Verify(issuedAfter, &RegisteredClaims{}))
+	assert.Equal(t, ErrUnknownKey, before.Verify(issuedAfter, &RegisteredClaims{}))
+}
+
+func TestNewKeySet_Errors(t *testing.T) {
+	_, rsaPublic := rsaKeyFiles(t)
+
+	_, err := NewKeySet(Config{SigningKid: "pub", Keys: []KeyConfig{{Kid: "pub", Alg: RS256, PublicKeyFile: rsaPublic}}})
+	assert.ErrorIs(t, err, ErrCannotSign)
+	_, err = NewKeySet(Config{Keys: []KeyConfig{{Kid: "pub", Alg: ES256, PublicKeyFile: rsaPublic}}})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = NewKeySet(Config{Keys: []KeyConfig{{Kid: "x", Alg: "none"}}})
+	assert.ErrorIs(t, err, ErrUnsupportedAlg)
+	_, err = NewKeySet(Config{Keys: []KeyConfig{{Kid: "x", Alg: HS256, Secret: "a"}, {Kid: "x", Alg: HS256, Secret: "b"}}})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	_, rsaPublic := rsaKeyFiles(t)
+	ks, err := NewKeySet(Config{Keys: []KeyConfig{
+		{Kid: "b-es", Alg: ES256, PrivateKeyFile: ecKeyFile(t)},
+		{Kid: "a-rs", Alg: RS256, PublicKeyFile: rsaPublic},
+		{Kid: "c-hs", Alg: HS256, Secret: "never published"},
+	}})
+	assert.Nil(t, err)
+
+	jwks := ks.JWKS()
+
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "a-rs", jwks.Keys[0].Kid)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+	assert.Equal(t, "b-es", jwks.Keys[1].Kid)
+	assert.Equal(t, "EC", jwks.Keys[1].Kty)
+	assert.Equal(t, "P-256", jwks.Keys[1].Crv)
+	assert.Len(t, jwks.Keys[1].X, 43)
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+)
+
+// Formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config logging config, level is one of debug, info, warn and error.
+type Config struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+func (c Config) format() (string, error) {
+	switch c.Format {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatText:
+		return FormatText, nil
+	}
+	return "", fmt.Errorf("unknown log format %q", c.Format)
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if _, err := ParseLevel(c.Level); err != nil {
+		problems.Addf("level", "%v", err)
+	}
+	if _, err := c.format(); err != nil {
+		problems.Addf("format", "%v", err)
+	}
+	return problems.Err()
+}
@@ -0,0 +1,221 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Level is the severity of an entry, entries below the level of a Logger are dropped.
+type Level int32
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name, the empty name is info.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return InfoLevel, nil
+	}
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", name)
+}
+
+// Field is a key value pair attached to an entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err creates the "error" field of err.
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error"}
+	}
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// output is shared by a Logger and the loggers derived from it with With.
+type output struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+	level  int32
+	now    func() time.Time
+}
+
+// Logger writes leveled entries, one line each, with its fields, the fields of the
+// context and the fields of the call. A nil *Logger drops every entry.
+type Logger struct {
+	output *output
+	fields []Field
+}
+
+// New creates a Logger writing to out, os.Stdout when nil.
+func New(out io.Writer, config Config) (*Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+	format, err := config.format()
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{output: &output{out: out, format: format, level: int32(level), now: time.Now}}, nil
+}
+
+// Discard returns a Logger dropping every entry.
+func Discard() *Logger {
+	return &Logger{output: &output{out: ioutil.Discard, format: FormatJSON, level: int32(ErrorLevel + 1), now: time.Now}}
+}
+
+// SetLevel changes the level of the logger and of the loggers sharing its output.
+func (l *Logger) SetLevel(level Level) {
+	if l == nil {
+		return
+	}
+	atomic.StoreInt32(&l.output.level, int32(level))
+}
+
+// Enabled reports whether entries of level are written.
+func (l *Logger) Enabled(level Level) bool {
+	if l == nil {
+		return false
+	}
+	return int32(level) >= atomic.LoadInt32(&l.output.level)
+}
+
+// With returns a Logger adding fields to every entry.
+func (l *Logger) With(fields ...Field) *Logger {
+	if l == nil {
+		return nil
+	}
+	return &Logger{output: l.output, fields: append(append([]Field{}, l.fields...), fields...)}
+}
+
+func (l *Logger) Debug(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, DebugLevel, msg, fields)
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, InfoLevel, msg, fields)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, WarnLevel, msg, fields)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, fields ...Field) {
+	l.log(ctx, ErrorLevel, msg, fields)
+}
+
+func (l *Logger) log(ctx context.Context, level Level, msg string, fields []Field) {
+	if !l.Enabled(level) {
+		return
+	}
+	all := make([]Field, 0, len(l.fields)+len(fields)+4)
+	all = append(all, l.fields...)
+	if ctx != nil {
+		all = append(all, FieldsFromContext(ctx)...)
+	}
+	all = append(all, fields...)
+	var line []byte
+	if l.output.format == FormatText {
+		line = textLine(l.output.now(), level, msg, all)
+	} else {
+		line = jsonLine(l.output.now(), level, msg, all)
+	}
+	l.output.mu.Lock()
+	defer l.output.mu.Unlock()
+	_, _ = l.output.out.Write(line)
+}
+
+// jsonLine encodes an entry as a JSON object, later fields replace earlier ones.
+func jsonLine(now time.Time, level Level, msg string, fields []Field) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for _, f := range fields {
+		entry[f.Key] = f.Value
+	}
+	entry["time"] = now.UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"time": entry["time"].(string), "level": level.String(), "msg": msg, "log_error": err.Error()})
+	}
+	return append(line, '\n')
+}
+
+// textLine encodes an entry as "time level msg key=value...", with the keys sorted.
+func textLine(now time.Time, level Level, msg string, fields []Field) []byte {
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		values[f.Key] = f.Value
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	b.WriteString(now.UTC().Format(time.RFC3339Nano))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for _, key := range keys {
+		value := fmt.Sprint(values[key])
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		b.WriteString(" " + key + "=" + value)
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+type ctxKey int
+
+const fieldsKey ctxKey = 0
+
+// WithFields returns a context carrying fields, added to every entry logged with it.
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	current := FieldsFromContext(ctx)
+	return context.WithValue(ctx, fieldsKey, append(append(make([]Field, 0, len(current)+len(fields)), current...), fields...))
+}
+
+// FieldsFromContext returns the fields carried by ctx.
+func FieldsFromContext(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey).([]Field)
+	return fields
+}
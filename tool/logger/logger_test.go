@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestLogger(t *testing.T, config Config) (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	l, err := New(out, config)
+	assert.Nil(t, err)
+	l.output.now = func() time.Time { return time.Date(2021, 9, 10, 12, 0, 0, 0, time.UTC) }
+	return l, out
+}
+
+func TestLogger_JSON(t *testing.T) {
+	l, out := newTestLogger(t, Config{Level: "info"})
+	ctx := WithFields(context.Background(), F("request_id", "req-1"))
+	ctx = WithFields(ctx, F("user_id", int64(7)))
+
+	l.Debug(ctx, "dropped")
+	l.With(F("component", "user")).Warn(ctx, "token lookup fail", Err(errors.New("token_api_unavailable")), F("user_id", int64(8)))
+
+	entry := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, map[string]interface{}{
+		"time":       "2021-09-10T12:00:00Z",
+		"level":      "warn",
+		"msg":        "token lookup fail",
+		"component":  "user",
+		"request_id": "req-1",
+		"user_id":    float64(8),
+		"error":      "token_api_unavailable",
+	}, entry)
+}
+
+func TestLogger_Text(t *testing.T) {
+	l, out := newTestLogger(t, Config{Level: "debug", Format: FormatText})
+
+	l.Debug(context.Background(), "config reloaded", F("file", "config/local.yml"), F("reason", "file changed"))
+
+	assert.Equal(t, "2021-09-10T12:00:00Z DEBUG config reloaded file=config/local.yml reason=\"file changed\"\n", out.String())
+}
+
+func TestLogger_SetLevel(t *testing.T) {
+	l, out := newTestLogger(t, Config{Level: "error"})
+	derived := l.With(F("component", "db"))
+
+	derived.Info(context.Background(), "dropped")
+	l.SetLevel(InfoLevel)
+	derived.Info(context.Background(), "written")
+
+	assert.Contains(t, out.String(), `"msg":"written"`)
+	assert.NotContains(t, out.String(), "dropped")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+	assert.EqualError(t, Config{Level: "verbose", Format: "xml"}.Validate(), `2 config problem(s): level: unknown log level "verbose"; format: unknown log format "xml"`)
+	_, err := New(nil, Config{Format: "xml"})
+	assert.NotNil(t, err)
+}
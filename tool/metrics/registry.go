@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets, in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry served by GET /metrics.
+var DefaultRegistry = NewRegistry()
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics and writes them in the Prometheus text format. Registering a
+// name twice returns the metric registered first, so packages can register on init.
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// Counter registers a counter partitioned by labels.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return r.register(name, &CounterVec{vec: newVec(name, help, labels)}).(*CounterVec)
+}
+
+// Histogram registers a histogram partitioned by labels, buckets are upper bounds in
+// ascending order.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return r.register(name, &HistogramVec{vec: newVec(name, help, labels), buckets: buckets}).(*HistogramVec)
+}
+
+// GaugeFunc registers a gauge whose value is read from fn on every scrape. Unlike the
+// other metrics a second registration replaces the first, so fn can follow a new source.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = &funcMetric{name: name, help: help, typ: "gauge", fn: fn}
+}
+
+// CounterFunc registers a counter whose value is read from fn on every scrape, see GaugeFunc.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = &funcMetric{name: name, help: help, typ: "counter", fn: fn}
+}
+
+func (r *Registry) register(name string, m metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, exist := r.metrics[name]; exist {
+		return existing
+	}
+	r.metrics[name] = m
+	return m
+}
+
+// Write writes every metric, sorted by name.
+func (r *Registry) Write(out io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.RUnlock()
+
+	w := bufio.NewWriter(out)
+	for _, m := range metrics {
+		m.write(w)
+	}
+	return w.Flush()
+}
+
+// Handler serves the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.Write(w)
+	})
+}
+
+type funcMetric struct {
+	name, help, typ string
+	fn              func() float64
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.name, m.help, m.typ)
+	writeSample(w, m.name, "", m.fn())
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
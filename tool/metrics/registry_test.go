@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.Counter("http_requests_total", "Requests served.", "route", "status")
+	latency := registry.Histogram("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	registry.GaugeFunc("db_open_connections", "Open connections.", func() float64 { return 3 })
+
+	requests.Inc("/get/{id}", "200")
+	requests.Add(2, "/get/{id}", "200")
+	requests.Inc(`/a"b`, "500")
+	latency.Observe(0.05, "/get/{id}")
+	latency.Observe(0.5, "/get/{id}")
+	latency.Observe(3, "/get/{id}")
+	assert.Same(t, requests, registry.Counter("http_requests_total", "Requests served.", "route", "status"))
+
+	out := bytes.Buffer{}
+	assert.Nil(t, registry.Write(&out))
+	assert.Equal(t, `# HELP db_open_connections Open connections.
+# TYPE db_open_connections gauge
+db_open_connections 3
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/get/{id}",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/get/{id}",le="1"} 2
+http_request_duration_seconds_bucket{route="/get/{id}",le="+Inf"} 3
+http_request_duration_seconds_sum{route="/get/{id}"} 3.55
+http_request_duration_seconds_count{route="/get/{id}"} 3
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="/a\"b",status="500"} 1
+http_requests_total{route="/get/{id}",status="200"} 3
+`, out.String())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	registry := NewRegistry()
+	registry.CounterFunc("jobs_total", "Jobs.", func() float64 { return 1 })
+	rec := httptest.NewRecorder()
+
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "# TYPE jobs_total counter\njobs_total 1\n")
+}
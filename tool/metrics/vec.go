@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vec holds the series of a metric, one per combination of label values.
+type vec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]interface{}
+	values map[string][]string
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: map[string]interface{}{}, values: map[string][]string{}}
+}
+
+// with returns the series of values, created by create when it doesn't exist yet.
+// Missing values are empty and extra values are dropped.
+func (v *vec) with(values []string, create func() interface{}) interface{} {
+	normalized := make([]string, len(v.labels))
+	copy(normalized, values)
+	key := strings.Join(normalized, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, exist := v.series[key]
+	if !exist {
+		s = create()
+		v.series[key] = s
+		v.values[key] = normalized
+	}
+	return s
+}
+
+// each calls fn with the formatted labels of every series, sorted by label values.
+func (v *vec) each(fn func(labels []string, s interface{})) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]interface{}, len(keys))
+	labels := make([][]string, len(keys))
+	for i, key := range keys {
+		series[i] = v.series[key]
+		labels[i] = v.formatLabels(v.values[key])
+	}
+	v.mu.Unlock()
+	for i := range keys {
+		fn(labels[i], series[i])
+	}
+}
+
+func (v *vec) formatLabels(values []string) []string {
+	pairs := make([]string, len(v.labels))
+	for i, label := range v.labels {
+		pairs[i] = label + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return pairs
+}
+
+// CounterVec counts events partitioned by labels.
+type CounterVec struct {
+	vec
+}
+
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// Inc adds one to the series of values, given in the order of the registered labels.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series of values.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	s := c.with(values, func() interface{} { return &counter{} }).(*counter)
+	s.mu.Lock()
+	s.value += delta
+	s.mu.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.each(func(labels []string, s interface{}) {
+		ctr := s.(*counter)
+		ctr.mu.Lock()
+		value := ctr.value
+		ctr.mu.Unlock()
+		writeSample(w, c.name, strings.Join(labels, ","), value)
+	})
+}
+
+// HistogramVec samples observations, such as latencies, in buckets partitioned by labels.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records value in the series of values, given in the order of the registered labels.
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	s := h.with(values, func() interface{} { return &histogram{counts: make([]uint64, len(h.buckets))} }).(*histogram)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.each(func(labels []string, s interface{}) {
+		hist := s.(*histogram)
+		hist.mu.Lock()
+		counts := append([]uint64{}, hist.counts...)
+		count, sum := hist.count, hist.sum
+		hist.mu.Unlock()
+		joined := strings.Join(labels, ",")
+		bounds := append(append(make([]float64, 0, len(h.buckets)+1), h.buckets...), math.Inf(1))
+		for i, bound := range bounds {
+			cumulative := count
+			if i < len(counts) {
+				cumulative = counts[i]
+			}
+			writeSample(w, h.name+"_bucket", strings.Join(append(append([]string{}, labels...), `le="`+formatFloat(bound)+`"`), ","), float64(cumulative))
+		}
+		writeSample(w, h.name+"_sum", joined, sum)
+		writeSample(w, h.name+"_count", joined, float64(count))
+	})
+}
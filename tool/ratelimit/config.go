@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+	"sort"
+	"strings"
+)
+
+// Key kinds identifying the client a bucket belongs to.
+const (
+	KeyIP     = "ip"
+	KeyAPIKey = "api_key"
+	KeyUser   = "user"
+)
+
+// Config rate limiting config. Routes are identified by method and chi pattern,
+// e.g. "GET /get/{id}"; routes without an entry use Default.
+type Config struct {
+	Enabled bool             `yaml:"enabled"`
+	Key     string           `yaml:"key"`
+	Default Limit            `yaml:"default"`
+	Routes  map[string]Limit `yaml:"routes"`
+}
+
+// Limit is a token bucket: Rate tokens per second are added up to Burst tokens,
+// every request takes one.
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Unlimited reports whether the limit lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	switch c.Key {
+	case "", KeyIP, KeyAPIKey, KeyUser:
+	default:
+		problems.Addf("key", "unknown key %q, expected one of %s, %s, %s", c.Key, KeyIP, KeyAPIKey, KeyUser)
+	}
+	c.Default.validate(&problems, "default")
+	routes := make([]string, 0, len(c.Routes))
+	for route := range c.Routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		path := fmt.Sprintf("routes[%q]", route)
+		if parts := strings.SplitN(route, " ", 2); len(parts) != 2 || parts[0] != strings.ToUpper(parts[0]) || !strings.HasPrefix(parts[1], "/") {
+			problems.Addf(path, "route must look like \"GET /path/{param}\"")
+		}
+		c.Routes[route].validate(&problems, path)
+	}
+	return problems.Err()
+}
+
+func (l Limit) validate(problems *validation.Problems, path string) {
+	if l.Rate < 0 {
+		problems.Addf(path+".rate", "must not be negative")
+	}
+	if l.Burst < 0 {
+		problems.Addf(path+".burst", "must not be negative")
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter applies the limits of a Config using a Store.
+type Limiter struct {
+	mu     sync.RWMutex
+	config Config
+	store  Store
+}
+
+func NewLimiter(config Config, store Store) *Limiter {
+	return &Limiter{
+		config: config,
+		store:  store,
+	}
+}
+
+// Reconfigure applies the limits of config from the next request on. The key kind
+// decides where the middleware runs, so changing it requires a restart and is ignored.
+func (l *Limiter) Reconfigure(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	config.Key = l.config.Key
+	l.config = config
+}
+
+func (l *Limiter) current() Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config
+}
+
+// KeyKind returns which client identity buckets are keyed by.
+func (l *Limiter) KeyKind() string {
+	if key := l.current().Key; key != "" {
+		return key
+	}
+	return KeyIP
+}
+
+// LimitFor returns the limit of route.
+func (l *Limiter) LimitFor(route string) Limit {
+	config := l.current()
+	if limit, exist := config.Routes[route]; exist {
+		return limit
+	}
+	return config.Default
+}
+
+// Allow takes a token from the bucket of client for route. unlimited is true when
+// the route has no limit, in which case the result is meaningless.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (result Result, unlimited bool, err error) {
+	limit := l.LimitFor(route)
+	if !l.current().Enabled || limit.Unlimited() {
+		return Result{Allowed: true}, true, nil
+	}
+	result, err = l.store.Take(ctx, route+"|"+client, limit)
+	return result, false, err
+}
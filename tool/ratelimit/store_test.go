@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take(ctx, "client", limit)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := store.Take(ctx, "client", limit)
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	other, _ := store.Take(ctx, "other", limit)
+	assert.True(t, other.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(ctx, "client", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	_, _ = store.Take(ctx, "a", Limit{Rate: 1, Burst: 1})
+	now = now.Add(2 * sweepInterval)
+	_, _ = store.Take(ctx, "b", Limit{Rate: 1, Burst: 1})
+
+	assert.Len(t, store.buckets, 1)
+	assert.Contains(t, store.buckets, "b")
+}
+
+func TestMemoryStore_SweepKeepsSlowLimits(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 0.01, Burst: 5}
+
+	for i := 0; i < 5; i++ {
+		result, _ := store.Take(ctx, "client", limit)
+		assert.True(t, result.Allowed)
+	}
+	now = now.Add(2 * sweepInterval)
+	_, _ = store.Take(ctx, "other", limit)
+
+	assert.Contains(t, store.buckets, "client", "a bucket refilling for 500s outlives the sweep")
+	result, _ := store.Take(ctx, "client", limit)
+	assert.True(t, result.Allowed, "1.2 tokens were added in 120s")
+	result, _ = store.Take(ctx, "client", limit)
+	assert.False(t, result.Allowed)
+
+	now = now.Add(600 * time.Second)
+	_, _ = store.Take(ctx, "other", limit)
+	assert.NotContains(t, store.buckets, "client", "a refilled bucket is swept")
+}
+
+func TestMemoryStore_SweepBoundsKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 2}
+
+	for i := 0; i < 1000; i++ {
+		_, _ = store.Take(ctx, fmt.Sprintf("client-%d", i), limit)
+	}
+	assert.Len(t, store.buckets, 1000, "keys accumulate between sweeps")
+
+	now = now.Add(sweepInterval)
+	_, _ = store.Take(ctx, "last", limit)
+	assert.Len(t, store.buckets, 1, "refilled buckets are dropped by the next sweep")
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(Config{
+		Enabled: true,
+		Default: Limit{Rate: 1, Burst: 1},
+		Routes:  map[string]Limit{"GET /open": {}},
+	}, NewMemoryStore())
+
+	result, unlimited, err := limiter.Allow(ctx, "GET /limited", "ip:1")
+	assert.Nil(t, err)
+	assert.False(t, unlimited)
+	assert.True(t, result.Allowed)
+	result, _, _ = limiter.Allow(ctx, "GET /limited", "ip:1")
+	assert.False(t, result.Allowed)
+	result, _, _ = limiter.Allow(ctx, "GET /other", "ip:1")
+	assert.True(t, result.Allowed, "buckets are per route")
+
+	_, unlimited, _ = limiter.Allow(ctx, "GET /open", "ip:1")
+	assert.True(t, unlimited)
+	assert.Equal(t, KeyIP, limiter.KeyKind())
+
+	disabled := NewLimiter(Config{Default: Limit{Rate: 1, Burst: 1}}, NewMemoryStore())
+	_, unlimited, _ = disabled.Allow(ctx, "GET /limited", "ip:1")
+	assert.True(t, unlimited)
+}
+
+func TestLimiter_Reconfigure(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLimiter(Config{Enabled: true, Key: KeyUser, Default: Limit{Rate: 1, Burst: 1}}, NewMemoryStore())
+	_, _, _ = limiter.Allow(ctx, "GET /items", "user:1")
+
+	limiter.Reconfigure(Config{Enabled: true, Key: KeyIP, Routes: map[string]Limit{"GET /items": {Rate: 1, Burst: 5}}})
+
+	assert.Equal(t, KeyUser, limiter.KeyKind(), "the key kind needs a restart")
+	assert.Equal(t, Limit{Rate: 1, Burst: 5}, limiter.LimitFor("GET /items"))
+	result, _, _ := limiter.Allow(ctx, "GET /items", "user:2")
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 5, result.Limit)
+}
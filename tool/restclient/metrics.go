@@ -0,0 +1,61 @@
+package restclient
+
+import (
+	"github.com/api_base/tool/metrics"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unknownLabel labels the calls to urls not built from a configured resource.
+const unknownLabel = "unknown"
+
+var outboundDuration = metrics.DefaultRegistry.Histogram("http_client_request_duration_seconds",
+	"Outbound HTTP call latency in seconds by external api and resource.", metrics.DefaultBuckets, "api", "resource", "status")
+
+// urlTemplate matches the urls BuildUrl builds for a resource.
+type urlTemplate struct {
+	api      string
+	resource string
+	pattern  *regexp.Regexp
+}
+
+// compileTemplates returns a matcher for every resource of config, ordered by api and
+// resource name. Templates that can't be parsed are left out.
+func compileTemplates(config Config) []urlTemplate {
+	templates := []urlTemplate{}
+	for _, api := range sortedApis(config.ExternalApiCalls) {
+		call := config.ExternalApiCalls[api]
+		for _, resource := range sortedResources(call.Resources) {
+			literals, err := splitTemplate(call.Resources[resource].RequestUri)
+			if err != nil {
+				continue
+			}
+			for i, literal := range literals {
+				literals[i] = regexp.QuoteMeta(literal)
+			}
+			pattern := "^" + regexp.QuoteMeta(call.ApiDomain) + strings.Join(literals, `[^/?&#]*`) + "$"
+			templates = append(templates, urlTemplate{api: api, resource: resource, pattern: regexp.MustCompile(pattern)})
+		}
+	}
+	return templates
+}
+
+// match returns the api and resource url was built from.
+func match(templates []urlTemplate, url string) (string, string) {
+	for _, t := range templates {
+		if t.pattern.MatchString(url) {
+			return t.api, t.resource
+		}
+	}
+	return unknownLabel, unknownLabel
+}
+
+func observeCall(api, resource string, status int, start time.Time) {
+	code := "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	outboundDuration.Observe(time.Since(start).Seconds(), api, resource, code)
+}
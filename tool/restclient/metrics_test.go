@@ -0,0 +1,71 @@
+package restclient
+
+import (
+	"bytes"
+	"context"
+	"github.com/api_base/tool/metrics"
+	"github.com/api_base/tool/tracing"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	templates := compileTemplates(Config{ExternalApiCalls: map[string]ExternalApiCall{
+		"token_api": {ApiDomain: "https://tokens.io", Resources: map[string]Resource{
+			"get_token":  {RequestUri: "/token/get/%s"},
+			"find_token": {RequestUri: "/token?token=%s"},
+			"broken":     {RequestUri: "/token/%"},
+		}},
+	}})
+
+	cases := map[string][2]string{
+		"https://tokens.io/token/get/42":       {"token_api", "get_token"},
+		"https://tokens.io/token?token=a%2Fb":  {"token_api", "find_token"},
+		"https://tokens.io/token/get/42/extra": {unknownLabel, unknownLabel},
+		"https://other.io/token/get/42":        {unknownLabel, unknownLabel},
+	}
+	for url, expected := range cases {
+		api, resource := match(templates, url)
+		assert.Equal(t, expected, [2]string{api, resource}, url)
+	}
+	assert.Len(t, templates, 2)
+}
+
+func TestRestClient_DoGetObservesCalls(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	rc, _ := NewRestClient(Config{TimeoutMillis: 1000, ExternalApiCalls: map[string]ExternalApiCall{
+		"metrics_api": {ApiDomain: srv.URL, Resources: map[string]Resource{"get_item": {RequestUri: "/items/%d"}}},
+	}})
+	url, _ := rc.BuildUrl("metrics_api", "get_item", 7)
+
+	assert.NotNil(t, rc.DoGet(context.Background(), url, &struct{}{}))
+
+	out := bytes.Buffer{}
+	assert.Nil(t, metrics.DefaultRegistry.Write(&out))
+	assert.Contains(t, out.String(), `http_client_request_duration_seconds_count{api="metrics_api",resource="get_item",status="404"} 1`)
+}
+
+func TestRestClient_DoGetPropagatesTraceparent(t *testing.T) {
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(tracing.TraceparentHeader)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	rc, _ := NewRestClient(Config{TimeoutMillis: 1000})
+	parent, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := tracing.ContextWithRemoteParent(context.Background(), parent)
+
+	assert.Nil(t, rc.DoGet(ctx, srv.URL+"/items?token=secret", &struct{}{}))
+
+	sc, ok := tracing.ParseTraceparent(<-received)
+	assert.True(t, ok)
+	assert.Equal(t, parent.TraceID, sc.TraceID)
+	assert.NotEqual(t, parent.SpanID, sc.SpanID)
+	assert.Equal(t, srv.URL+"/items", redactQuery(srv.URL+"/items?token=secret"))
+}
@@ -1,23 +1,47 @@
 package restclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/api_base/tool/tracing"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
 type RestClient interface {
 	BuildUrl(externalApi string, resource string, params ...interface{}) (string, error)
 	DoGet(ctx context.Context, url string, result interface{}, additionalHeaders ...Header) error
+	DoPost(ctx context.Context, url string, body interface{}, result interface{}, additionalHeaders ...Header) error
+	Reconfigure(config Config)
+	CloseIdleConnections()
 }
 
 type restClient struct {
-	config Config
-	client http.Client
+	mu    sync.RWMutex
+	state clientState
+}
+
+// clientState is what a config change replaces.
+type clientState struct {
+	config    Config
+	client    *http.Client
+	templates []urlTemplate
+}
+
+// StatusError is returned by DoGet and DoPost when the external api answers with a non 2xx status code.
+type StatusError struct {
+	StatusCode int
+	Url        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.Url, e.StatusCode)
 }
 
 type Header struct {
@@ -26,39 +50,86 @@ type Header struct {
 }
 
 func NewRestClient(config Config) (RestClient, error) {
-	client := http.Client{
-		Timeout: config.TimeoutMillis * time.Millisecond,
-	}
 	return &restClient{
-		config: config,
-		client: client,
+		state: newState(config),
 	}, nil
 }
 
-func (rc restClient) BuildUrl(externalApi string, resource string, params ...interface{}) (string, error) {
+func newState(config Config) clientState {
+	return clientState{
+		config: config,
+		client: &http.Client{
+			Timeout: config.TimeoutMillis * time.Millisecond,
+		},
+		templates: compileTemplates(config),
+	}
+}
+
+// Reconfigure applies config, timeouts and domains, to the requests built from now on.
+func (rc *restClient) Reconfigure(config Config) {
+	state := newState(config)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.state = state
+}
+
+func (rc *restClient) current() clientState {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.state
+}
+
+func (rc *restClient) BuildUrl(externalApi string, resource string, params ...interface{}) (string, error) {
 	url := ""
-	if val, exist := rc.config.ExternalApiCalls[externalApi]; exist {
+	if val, exist := rc.current().config.ExternalApiCalls[externalApi]; exist {
 		url = val.ApiDomain + fmt.Sprintf(val.Resources[resource].RequestUri, params...)
 		return url, nil
 	}
 	return url, errors.New("resource_not_found")
 }
 
-func (rc restClient) DoGet(ctx context.Context, url string, result interface{}, additionalHeaders ...Header) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (rc *restClient) DoGet(ctx context.Context, url string, result interface{}, additionalHeaders ...Header) error {
+	return rc.do(ctx, http.MethodGet, url, nil, result, additionalHeaders)
+}
+
+// DoPost sends body encoded as json. A nil result ignores the response body.
+func (rc *restClient) DoPost(ctx context.Context, url string, body interface{}, result interface{}, additionalHeaders ...Header) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return rc.do(ctx, http.MethodPost, url, payload, result, additionalHeaders)
+}
+
+func (rc *restClient) do(ctx context.Context, method, url string, payload []byte, result interface{}, additionalHeaders []Header) (err error) {
+	state := rc.current()
+	api, resource := match(state.templates, url)
+	ctx, span := tracing.Start(ctx, method+" "+api+"/"+resource, tracing.KindClient)
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("http.url", redactQuery(url))
+	span.SetAttribute("external_api", api)
+	span.SetAttribute("resource", resource)
+	defer func() { span.End(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
+	req.Header.Set(tracing.TraceparentHeader, span.Context().Traceparent())
 	for _, header := range additionalHeaders {
 		req.Header.Add(header.Key, header.Value)
 	}
-	res, err := rc.client.Do(req)
+	start := time.Now()
+	res, err := state.client.Do(req)
 	if err != nil {
+		observeCall(api, resource, 0, start)
 		return err
 	}
+	span.SetAttribute("http.status_code", res.StatusCode)
 	body, err := ioutil.ReadAll(res.Body)
+	observeCall(api, resource, res.StatusCode, start)
 	if err != nil {
 		return err
 	}
@@ -66,9 +137,27 @@ func (rc restClient) DoGet(ctx context.Context, url string, result interface{},
 	if err != nil {
 		return err
 	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return &StatusError{StatusCode: res.StatusCode, Url: url}
+	}
+	if result == nil {
+		return nil
+	}
 	err = json.Unmarshal(body, result)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// redactQuery drops the query of url, which may carry credentials such as token values.
+func redactQuery(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
+
+func (rc *restClient) CloseIdleConnections() {
+	rc.current().client.CloseIdleConnections()
+}
@@ -1 +1,77 @@
 package restclient
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestClient_Reconfigure(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"first"}`))
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"name":"second"}`))
+	}))
+	defer second.Close()
+	config := func(domain string, timeout time.Duration) Config {
+		return Config{
+			TimeoutMillis: timeout,
+			ExternalApiCalls: map[string]ExternalApiCall{
+				"api": {ApiDomain: domain, Resources: map[string]Resource{"get": {RequestUri: "/items/%s"}}},
+			},
+		}
+	}
+	rc, err := NewRestClient(config(first.URL, 1000))
+	assert.Nil(t, err)
+	get := func() (string, error) {
+		url, err := rc.BuildUrl("api", "get", "1")
+		assert.Nil(t, err)
+		result := struct{ Name string }{}
+		err = rc.DoGet(context.Background(), url, &result)
+		return result.Name, err
+	}
+
+	name, err := get()
+	assert.Nil(t, err)
+	assert.Equal(t, "first", name)
+
+	rc.Reconfigure(config(second.URL, 10))
+	_, err = get()
+	assert.NotNil(t, err, "the new timeout applies")
+
+	rc.Reconfigure(config(second.URL, 1000))
+	name, err = get()
+	assert.Nil(t, err)
+	assert.Equal(t, "second", name)
+}
+
+func TestRestClient_DoPost(t *testing.T) {
+	var method, key, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		method, key, body = r.Method, r.Header.Get("Idempotency-Key"), string(raw)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	rc, _ := NewRestClient(Config{
+		TimeoutMillis: 1000,
+		ExternalApiCalls: map[string]ExternalApiCall{
+			"api": {ApiDomain: server.URL, Resources: map[string]Resource{"post": {RequestUri: "/items"}}},
+		},
+	})
+	url, _ := rc.BuildUrl("api", "post")
+
+	err := rc.DoPost(context.Background(), url, map[string]string{"name": "one"}, nil, Header{Key: "Idempotency-Key", Value: "k1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "k1", key)
+	assert.JSONEq(t, `{"name":"one"}`, body)
+}
@@ -0,0 +1,141 @@
+package restclient
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	resourcesMu       sync.RWMutex
+	expectedResources = map[string]map[string]int{}
+)
+
+// RegisterResource declares that the resource of externalApi is built by BuildUrl with
+// params arguments, so Validate reports missing resources and request_uri templates with
+// a different number of verbs. Repositories call it from their init function.
+func RegisterResource(externalApi, resource string, params int) {
+	resourcesMu.Lock()
+	defer resourcesMu.Unlock()
+	if expectedResources[externalApi] == nil {
+		expectedResources[externalApi] = map[string]int{}
+	}
+	expectedResources[externalApi][resource] = params
+}
+
+// Validate reports every problem of the config, including the registered resources
+// missing from it.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if c.TimeoutMillis <= 0 {
+		problems.Addf("timeout", "must be a positive number of milliseconds")
+	}
+	for _, name := range sortedApis(c.ExternalApiCalls) {
+		call := c.ExternalApiCalls[name]
+		path := "external_calls." + name
+		if u, err := url.Parse(call.ApiDomain); call.ApiDomain == "" || err != nil || u.Scheme == "" || u.Host == "" {
+			problems.Addf(path+".domain", "must be an absolute url, got %q", call.ApiDomain)
+		}
+		for _, resource := range sortedResources(call.Resources) {
+			validateResource(&problems, path+".resources."+resource, name, resource, call.Resources[resource].RequestUri)
+		}
+	}
+	for _, path := range c.missingResources() {
+		problems.Addf(path, "is required")
+	}
+	return problems.Err()
+}
+
+func (c Config) missingResources() []string {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	missing := []string{}
+	for name, resources := range expectedResources {
+		for resource := range resources {
+			if _, ok := c.ExternalApiCalls[name].Resources[resource]; !ok {
+				missing = append(missing, "external_calls."+name+".resources."+resource)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func validateResource(problems *validation.Problems, path, api, resource, uri string) {
+	if uri == "" {
+		problems.Addf(path+".request_uri", "is required")
+		return
+	}
+	params, err := templateParams(uri)
+	if err != nil {
+		problems.Addf(path+".request_uri", "%v", err)
+		return
+	}
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	if expected, ok := expectedResources[api][resource]; ok && expected != params {
+		problems.Addf(path+".request_uri", "expects %d parameter(s), %q has %d", expected, uri, params)
+	}
+}
+
+// templateParams counts the fmt verbs of a request_uri template.
+func templateParams(uri string) (int, error) {
+	literals, err := splitTemplate(uri)
+	return len(literals) - 1, err
+}
+
+// splitTemplate returns the literal text around the fmt verbs of a request_uri template,
+// with "%%" unescaped.
+func splitTemplate(uri string) ([]string, error) {
+	literals := []string{}
+	literal := strings.Builder{}
+	for i := 0; i < len(uri); i++ {
+		if uri[i] != '%' {
+			literal.WriteByte(uri[i])
+			continue
+		}
+		i++
+		if i < len(uri) && uri[i] == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+		for i < len(uri) && isVerbModifier(uri[i]) {
+			i++
+		}
+		if i == len(uri) || !isLetter(uri[i]) {
+			return []string{""}, fmt.Errorf("malformed verb in %q", uri)
+		}
+		literals = append(literals, literal.String())
+		literal.Reset()
+	}
+	return append(literals, literal.String()), nil
+}
+
+func isVerbModifier(c byte) bool {
+	return c == '+' || c == '-' || c == '#' || c == ' ' || c == '0' || c == '.' || (c >= '1' && c <= '9')
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func sortedApis(m map[string]ExternalApiCall) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedResources(m map[string]Resource) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
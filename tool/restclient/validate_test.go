@@ -0,0 +1,70 @@
+package restclient
+
+import (
+	"github.com/api_base/tool/validation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTemplateParams(t *testing.T) {
+	cases := map[string]int{
+		"/token/get/%s":           1,
+		"/token?token=%s&page=%d": 2,
+		"/users/%05d":             1,
+		"/status":                 0,
+		"/discount/100%%":         0,
+	}
+	for uri, expected := range cases {
+		params, err := templateParams(uri)
+		assert.Nil(t, err, uri)
+		assert.Equal(t, expected, params, uri)
+	}
+	_, err := templateParams("/token/%")
+	assert.NotNil(t, err)
+	_, err = templateParams("/token/%[1]s")
+	assert.NotNil(t, err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	RegisterResource("validate_api", "get_item", 1)
+	RegisterResource("validate_api", "list_items", 0)
+	defer func() {
+		resourcesMu.Lock()
+		delete(expectedResources, "validate_api")
+		resourcesMu.Unlock()
+	}()
+
+	valid := Config{
+		TimeoutMillis: 100,
+		ExternalApiCalls: map[string]ExternalApiCall{
+			"validate_api": {
+				ApiDomain: "http://localhost:8080",
+				Resources: map[string]Resource{
+					"get_item":   {RequestUri: "/items/%s"},
+					"list_items": {RequestUri: "/items"},
+				},
+			},
+		},
+	}
+	assert.Nil(t, valid.Validate())
+
+	invalid := Config{
+		ExternalApiCalls: map[string]ExternalApiCall{
+			"validate_api": {
+				ApiDomain: "localhost",
+				Resources: map[string]Resource{
+					"get_item": {RequestUri: "/items/%s/%s"},
+					"other":    {},
+				},
+			},
+		},
+	}
+	err := invalid.Validate()
+	assert.Equal(t, []string{
+		"timeout: must be a positive number of milliseconds",
+		`external_calls.validate_api.domain: must be an absolute url, got "localhost"`,
+		`external_calls.validate_api.resources.get_item.request_uri: expects 1 parameter(s), "/items/%s/%s" has 2`,
+		"external_calls.validate_api.resources.other.request_uri: is required",
+		"external_calls.validate_api.resources.list_items: is required",
+	}, []string(err.(validation.Problems)))
+}
@@ -0,0 +1,21 @@
+package secrets
+
+// default values
+const (
+	defaultKeyEnv = "SECRETS_KEY"
+)
+
+// Config secrets resolution config. EncryptedFile is a yaml map of secret names to
+// values sealed with Encrypt, the base64 AES-256 key is read from the KeyEnv variable.
+type Config struct {
+	EncryptedFile string `yaml:"encrypted_file"`
+	KeyEnv        string `yaml:"key_env"`
+}
+
+// KeyEnvName returns the name of the variable holding the encryption key.
+func (c Config) KeyEnvName() string {
+	if c.KeyEnv == "" {
+		return defaultKeyEnv
+	}
+	return c.KeyEnv
+}
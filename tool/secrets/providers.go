@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidKey = errors.New("secrets_invalid_key")
+
+// NewEnvProvider creates a Provider reading the variables of environ, in os.Environ format.
+func NewEnvProvider(environ []string) Provider {
+	values := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		if i := strings.Index(entry, "="); i > 0 {
+			values[entry[:i]] = entry[i+1:]
+		}
+	}
+	return ProviderFunc(func(name string) (string, error) {
+		value, exist := values[name]
+		if !exist {
+			return "", ErrNotFound
+		}
+		return value, nil
+	})
+}
+
+// NewFileProvider creates a Provider reading whole files, such as the Docker and
+// Kubernetes secret mounts. The trailing line break is dropped.
+func NewFileProvider() Provider {
+	return ProviderFunc(func(path string) (string, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	})
+}
+
+type encryptedFileProvider struct {
+	path   string
+	keyEnv string
+	env    Provider
+	once   sync.Once
+	values map[string]string
+	err    error
+}
+
+// NewEncryptedFileProvider creates a Provider decrypting the entries of the yaml file at
+// path with the key held by the keyEnv variable. The file is read on first use so a
+// missing key only fails when an encrypted secret is referenced.
+func NewEncryptedFileProvider(path, keyEnv string, env Provider) Provider {
+	return &encryptedFileProvider{path: path, keyEnv: keyEnv, env: env}
+}
+
+func (p *encryptedFileProvider) Secret(name string) (string, error) {
+	p.once.Do(p.load)
+	if p.err != nil {
+		return "", p.err
+	}
+	value, exist := p.values[name]
+	if !exist {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (p *encryptedFileProvider) load() {
+	if p.path == "" {
+		p.err = errors.New("secrets.encrypted_file is not configured")
+		return
+	}
+	encodedKey, err := p.env.Secret(p.keyEnv)
+	if err != nil {
+		p.err = fmt.Errorf("%w: %s: %v", ErrInvalidKey, p.keyEnv, err)
+		return
+	}
+	content, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		p.err = err
+		return
+	}
+	sealed := map[string]string{}
+	if p.err = yaml.Unmarshal(content, &sealed); p.err != nil {
+		return
+	}
+	p.values = make(map[string]string, len(sealed))
+	for name, value := range sealed {
+		plain, err := Decrypt(encodedKey, name, value)
+		if err != nil {
+			p.err = fmt.Errorf("decrypt %q: %w", name, err)
+			return
+		}
+		p.values[name] = plain
+	}
+}
+
+// Encrypt seals plain with AES-256-GCM for the entry name of an encrypted file. key is
+// the base64 encoded 32 bytes key, the result is the base64 encoded nonce and ciphertext.
+func Encrypt(key, name, plain string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt for the same entry name.
+func Decrypt(key, name, value string) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("sealed value too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("%w: expected a base64 encoded 32 bytes key", ErrInvalidKey)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownProvider = errors.New("secrets_unknown_provider")
+	ErrNotFound        = errors.New("secrets_not_found")
+)
+
+// Provider returns the secret identified by ref.
+type Provider interface {
+	Secret(ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ref string) (string, error)
+
+func (f ProviderFunc) Secret(ref string) (string, error) {
+	return f(ref)
+}
+
+// Resolver replaces values written as ${provider:ref} with the secret returned by the
+// provider, e.g. ${env:DB_PASSWORD}, ${file:/run/secrets/db} or ${enc:db_password}.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver creates a Resolver with the env, file and enc providers. environ is
+// given in os.Environ format and is also where the encryption key is read from.
+func NewResolver(config Config, environ []string) *Resolver {
+	env := NewEnvProvider(environ)
+	return &Resolver{
+		providers: map[string]Provider{
+			"env":  env,
+			"file": NewFileProvider(),
+			"enc":  NewEncryptedFileProvider(config.EncryptedFile, config.KeyEnvName(), env),
+		},
+	}
+}
+
+// Register adds or replaces the provider used for references with the given name.
+func (r *Resolver) Register(name string, provider Provider) {
+	r.providers[name] = provider
+}
+
+// Resolve returns the secret referenced by value, values that aren't a reference are
+// returned unchanged.
+func (r *Resolver) Resolve(value string) (string, error) {
+	name, ref, ok := parseReference(value)
+	if !ok {
+		return value, nil
+	}
+	provider, exist := r.providers[name]
+	if !exist {
+		return "", fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+	secret, err := provider.Secret(ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s secret %q: %w", name, ref, err)
+	}
+	return secret, nil
+}
+
+// IsReference reports whether value is written as ${provider:ref}.
+func IsReference(value string) bool {
+	_, _, ok := parseReference(value)
+	return ok
+}
+
+func parseReference(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") {
+		return "", "", false
+	}
+	parts := strings.SplitN(value[2:len(value)-1], ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
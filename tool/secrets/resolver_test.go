@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	secretFile := filepath.Join(dir, "db")
+	assert.Nil(t, ioutil.WriteFile(secretFile, []byte("s3cret\n"), 0600))
+	resolver := NewResolver(Config{}, []string{"DB_PASSWORD=from-env"})
+	resolver.Register("static", ProviderFunc(func(ref string) (string, error) { return "static-" + ref, nil }))
+
+	cases := map[string]string{
+		"plain":                      "plain",
+		"${env:DB_PASSWORD}":         "from-env",
+		"${file:" + secretFile + "}": "s3cret",
+		"${static:value}":            "static-value",
+		"${env}":                     "${env}",
+		"prefix ${env:DB_PASSWORD}":  "prefix ${env:DB_PASSWORD}",
+	}
+	for value, expected := range cases {
+		secret, err := resolver.Resolve(value)
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, secret, value)
+	}
+
+	_, err = resolver.Resolve("${vault:db}")
+	assert.True(t, errors.Is(err, ErrUnknownProvider))
+	_, err = resolver.Resolve("${env:MISSING}")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = resolver.Resolve("${enc:db}")
+	assert.NotNil(t, err)
+}
+
+func TestEncryptedFileProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	sealed, err := Encrypt(key, "db_password", "s3cret")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "secrets.enc.yml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("db_password: "+sealed+"\n"), 0600))
+
+	resolver := NewResolver(Config{EncryptedFile: path, KeyEnv: "APP_SECRETS_KEY"}, []string{"APP_SECRETS_KEY=" + key})
+	secret, err := resolver.Resolve("${enc:db_password}")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", secret)
+	_, err = resolver.Resolve("${enc:other}")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = Decrypt(key, "other_name", sealed)
+	assert.NotNil(t, err, "values are bound to their entry name")
+	withoutKey := NewResolver(Config{EncryptedFile: path}, nil)
+	_, err = withoutKey.Resolve("${enc:db_password}")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"github.com/api_base/tool/validation"
+	"net"
+	"time"
+)
+
+// default values
+const (
+	defaultAddress         = ":3000"
+	defaultShutdownTimeout = 15 * time.Second
+)
+
+// Config http server config, timeouts are expressed in seconds
+type Config struct {
+	Address                string        `yaml:"address"`
+	ReadTimeoutSeconds     time.Duration `yaml:"read_timeout_seconds"`
+	WriteTimeoutSeconds    time.Duration `yaml:"write_timeout_seconds"`
+	IdleTimeoutSeconds     time.Duration `yaml:"idle_timeout_seconds"`
+	ShutdownTimeoutSeconds time.Duration `yaml:"shutdown_timeout_seconds"`
+	MaxHeaderBytes         int           `yaml:"max_header_bytes"`
+}
+
+func (c Config) address() string {
+	if c.Address == "" {
+		return defaultAddress
+	}
+	return c.Address
+}
+
+// ShutdownTimeout returns how long in-flight requests, and the dependencies stopped
+// after them, are given to finish.
+func (c Config) ShutdownTimeout() time.Duration {
+	if c.ShutdownTimeoutSeconds <= 0 {
+		return defaultShutdownTimeout
+	}
+	return c.ShutdownTimeoutSeconds * time.Second
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	if _, _, err := net.SplitHostPort(c.address()); err != nil {
+		problems.Addf("address", "%v", err)
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read_timeout_seconds", c.ReadTimeoutSeconds},
+		{"write_timeout_seconds", c.WriteTimeoutSeconds},
+		{"idle_timeout_seconds", c.IdleTimeoutSeconds},
+		{"shutdown_timeout_seconds", c.ShutdownTimeoutSeconds},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			problems.Addf(timeout.name, "must not be negative")
+		}
+	}
+	if c.MaxHeaderBytes < 0 {
+		problems.Addf("max_header_bytes", "must not be negative")
+	}
+	return problems.Err()
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type Server struct {
+	config Config
+	http   *http.Server
+}
+
+func NewServer(config Config, handler http.Handler) *Server {
+	return &Server{
+		config: config,
+		http: &http.Server{
+			Addr:           config.address(),
+			Handler:        handler,
+			ReadTimeout:    config.ReadTimeoutSeconds * time.Second,
+			WriteTimeout:   config.WriteTimeoutSeconds * time.Second,
+			IdleTimeout:    config.IdleTimeoutSeconds * time.Second,
+			MaxHeaderBytes: config.MaxHeaderBytes,
+		},
+	}
+}
+
+// ListenAndServe serves until SIGINT or SIGTERM is received, then stops accepting
+// connections and waits for in-flight requests up to the shutdown timeout.
+func (s *Server) ListenAndServe() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is done and then shuts the server down gracefully.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.http.Serve(listener)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout())
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
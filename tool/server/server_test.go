@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	srv := NewServer(Config{ReadTimeoutSeconds: 5, WriteTimeoutSeconds: 10, IdleTimeoutSeconds: 60, MaxHeaderBytes: 4096}, http.NotFoundHandler())
+
+	assert.Equal(t, defaultAddress, srv.http.Addr)
+	assert.Equal(t, 5*time.Second, srv.http.ReadTimeout)
+	assert.Equal(t, 10*time.Second, srv.http.WriteTimeout)
+	assert.Equal(t, time.Minute, srv.http.IdleTimeout)
+	assert.Equal(t, 4096, srv.http.MaxHeaderBytes)
+	assert.Equal(t, defaultShutdownTimeout, srv.config.ShutdownTimeout())
+}
+
+func TestServer_ServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("done"))
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	srv := NewServer(Config{ShutdownTimeoutSeconds: 5}, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, listener) }()
+
+	bodies := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			bodies <- err.Error()
+			return
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		bodies <- string(body)
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-served:
+		t.Fatal("server stopped before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, "done", <-bodies)
+	assert.Nil(t, <-served)
+}
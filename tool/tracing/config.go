@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"fmt"
+	"github.com/api_base/tool/validation"
+	"net/url"
+	"time"
+)
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// default values
+const (
+	defaultServiceName   = "api_base"
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+	defaultExportTimeout = 10 * time.Second
+)
+
+// Config tracing config. Endpoint is the base url of an OTLP/HTTP collector, spans are
+// posted as JSON to {endpoint}/v1/traces.
+type Config struct {
+	Exporter             string            `yaml:"exporter"`
+	ServiceName          string            `yaml:"service_name"`
+	SampleRatio          float64           `yaml:"sample_ratio"`
+	Endpoint             string            `yaml:"endpoint"`
+	Headers              map[string]string `yaml:"headers"`
+	BatchSize            int               `yaml:"batch_size"`
+	FlushIntervalSeconds time.Duration     `yaml:"flush_interval_seconds"`
+	TimeoutMillis        time.Duration     `yaml:"timeout"`
+}
+
+func (c Config) serviceName() string {
+	if c.ServiceName == "" {
+		return defaultServiceName
+	}
+	return c.ServiceName
+}
+
+func (c Config) batchSize() int {
+	if c.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return c.BatchSize
+}
+
+func (c Config) flushInterval() time.Duration {
+	if c.FlushIntervalSeconds <= 0 {
+		return defaultFlushInterval
+	}
+	return c.FlushIntervalSeconds * time.Second
+}
+
+func (c Config) timeout() time.Duration {
+	if c.TimeoutMillis <= 0 {
+		return defaultExportTimeout
+	}
+	return c.TimeoutMillis * time.Millisecond
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	problems := validation.Problems{}
+	switch c.Exporter {
+	case "", ExporterNone, ExporterStdout:
+	case ExporterOTLP:
+		if u, err := url.Parse(c.Endpoint); c.Endpoint == "" || err != nil || u.Scheme == "" || u.Host == "" {
+			problems.Addf("endpoint", "must be an absolute url, got %q", c.Endpoint)
+		}
+	default:
+		problems.Addf("exporter", "unknown exporter %q, expected one of %s, %s, %s", c.Exporter, ExporterNone, ExporterStdout, ExporterOTLP)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		problems.Addf("sample_ratio", "must be between 0 and 1")
+	}
+	return problems.Err()
+}
+
+// NewTracerFromConfig creates the tracer of config, the stdout exporter writes to os.Stdout.
+func NewTracerFromConfig(config Config) (*Tracer, error) {
+	switch config.Exporter {
+	case "", ExporterNone:
+		return NewTracer(NoopExporter{}, 0), nil
+	case ExporterStdout:
+		return NewTracer(NewStdoutExporter(stdout, config.serviceName()), config.SampleRatio), nil
+	case ExporterOTLP:
+		return NewTracer(NewOTLPExporter(config), config.SampleRatio), nil
+	}
+	return nil, fmt.Errorf("unknown exporter %q", config.Exporter)
+}
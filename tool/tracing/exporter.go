@@ -0,0 +1,296 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var stdout io.Writer = os.Stdout
+
+var (
+	errorHandlerMu sync.RWMutex
+	errorHandler   = func(error) {}
+)
+
+// SetErrorHandler sets the function receiving the errors of the exporters, which are
+// dropped by default.
+func SetErrorHandler(handler func(error)) {
+	errorHandlerMu.Lock()
+	defer errorHandlerMu.Unlock()
+	errorHandler = handler
+}
+
+func handleError(err error) {
+	errorHandlerMu.RLock()
+	handler := errorHandler
+	errorHandlerMu.RUnlock()
+	handler(err)
+}
+
+// NoopExporter drops every span.
+type NoopExporter struct{}
+
+func (NoopExporter) Export(SpanData) {}
+
+func (NoopExporter) Shutdown(context.Context) error { return nil }
+
+type stdoutSpan struct {
+	Service    string                 `json:"service"`
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	Start      string                 `json:"start"`
+	DurationMs float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type stdoutExporter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	service string
+}
+
+// NewStdoutExporter writes every span as a JSON line to out.
+func NewStdoutExporter(out io.Writer, service string) Exporter {
+	return &stdoutExporter{out: out, service: service}
+}
+
+func (e *stdoutExporter) Export(span SpanData) {
+	line := stdoutSpan{
+		Service:    e.service,
+		TraceID:    span.Context.TraceID.String(),
+		SpanID:     span.Context.SpanID.String(),
+		Name:       span.Name,
+		Kind:       span.Kind,
+		Start:      span.Start.UTC().Format(time.RFC3339Nano),
+		DurationMs: float64(span.End.Sub(span.Start)) / float64(time.Millisecond),
+		Attributes: span.Attributes,
+		Error:      span.Error,
+	}
+	if span.Parent != (SpanID{}) {
+		line.ParentID = span.Parent.String()
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.out.Write(append(b, '\n'))
+}
+
+func (e *stdoutExporter) Shutdown(context.Context) error { return nil }
+
+// otlpExporter batches spans and posts them to an OTLP/HTTP collector using the JSON
+// encoding of the protocol.
+type otlpExporter struct {
+	config  Config
+	client  *http.Client
+	mu      sync.Mutex
+	pending []SpanData
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewOTLPExporter creates an exporter posting batches every flush interval or as soon
+// as a batch is full. Spans arriving while a full batch is being sent are dropped.
+func NewOTLPExporter(config Config) Exporter {
+	e := &otlpExporter{
+		config:  config,
+		client:  &http.Client{Timeout: config.timeout()},
+		flush:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *otlpExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) >= 2*e.config.batchSize() {
+		return
+	}
+	e.pending = append(e.pending, span)
+	if len(e.pending) >= e.config.batchSize() {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.config.flushInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.done:
+			e.send()
+			return
+		}
+		e.send()
+	}
+}
+
+func (e *otlpExporter) send() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := e.post(spans); err != nil {
+		handleError(fmt.Errorf("export %d spans fail: %w", len(spans), err))
+	}
+}
+
+func (e *otlpExporter) post(spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(e.config.serviceName(), spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(e.config.Endpoint, "/")+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("collector responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON encoding, see opentelemetry-proto trace/v1 and the OTLP/HTTP JSON mapping.
+type (
+	otlpExportRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+var otlpKinds = map[string]int{KindInternal: 1, KindServer: 2, KindClient: 3}
+
+func otlpRequest(service string, spans []SpanData) otlpExportRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		encoded[i] = otlpSpan{
+			TraceID:           span.Context.TraceID.String(),
+			SpanID:            span.Context.SpanID.String(),
+			Name:              span.Name,
+			Kind:              otlpKinds[span.Kind],
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.Parent != (SpanID{}) {
+			encoded[i].ParentSpanID = span.Parent.String()
+		}
+		if span.Error != "" {
+			encoded[i].Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+	}
+	return otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/api_base/tool/tracing"}, Spans: encoded}},
+	}}}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, len(keys))
+	for i, key := range keys {
+		kvs[i] = otlpKeyValue{Key: key, Value: otlpValue(attributes[key])}
+	}
+	return kvs
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch value := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": value}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
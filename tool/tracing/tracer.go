@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Span kinds
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// Exporter receives the sampled spans once they end.
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// SpanData is the immutable record of an ended span.
+type SpanData struct {
+	Name       string
+	Kind       string
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Error      string
+}
+
+// Span is an operation in progress, see Start. A nil *Span is valid and does nothing.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// Context returns the span context to propagate to the children of the span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.Context
+}
+
+// SetName renames the span, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute records a key value pair describing the operation, until the span ends.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes[key] = value
+	}
+}
+
+// End ends the span, marking it as failed when err is not nil. Later calls do nothing.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	s.mu.Unlock()
+	if data.Context.Sampled {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+// Tracer starts spans and hands the sampled ones to its exporter.
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	now         func() time.Time
+}
+
+// NewTracer creates a Tracer sampling sampleRatio of the root spans, children follow
+// the decision of their parent.
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{exporter: exporter, sampleRatio: sampleRatio, now: time.Now}
+}
+
+// Start starts a span child of the span of ctx, or of the remote parent of ctx, and
+// returns a context holding it.
+func (t *Tracer) Start(ctx context.Context, name, kind string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx).Context()
+	if !parent.Valid() {
+		parent, _ = ctx.Value(remoteKey).(SpanContext)
+	}
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+	if !parent.Valid() {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sampleRatio >= 1 || (t.sampleRatio > 0 && rand.Float64() < t.sampleRatio)
+	}
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       kind,
+			Context:    sc,
+			Parent:     parent.SpanID,
+			Start:      t.now(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// Shutdown flushes the spans pending export.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.exporter.Shutdown(ctx)
+}
+
+type ctxKey int
+
+const (
+	spanKey ctxKey = iota
+	remoteKey
+)
+
+// SpanFromContext returns the span of ctx, nil when there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a context whose next span continues the trace of sc,
+// received from another process.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+var (
+	globalMu sync.RWMutex
+	global   = NewTracer(NoopExporter{}, 0)
+)
+
+// SetTracer replaces the tracer used by Start, a no-op tracer by default.
+func SetTracer(t *Tracer) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	global = t
+}
+
+// Start starts a span with the tracer set by SetTracer, see Tracer.Start.
+func Start(ctx context.Context, name, kind string) (context.Context, *Span) {
+	globalMu.RLock()
+	t := global
+	globalMu.RUnlock()
+	return t.Start(ctx, name, kind)
+}
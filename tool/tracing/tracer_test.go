@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordingExporter struct {
+	spans []SpanData
+}
+
+func (e *recordingExporter) Export(span SpanData) { e.spans = append(e.spans, span) }
+
+func (e *recordingExporter) Shutdown(context.Context) error { return nil }
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.True(t, ok, "future versions may add fields")
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestTracer_Start(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, 1)
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, server := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "GET /get/{id}", KindServer)
+	_, child := tracer.Start(ctx, "user.Service.Get", KindInternal)
+	child.SetAttribute("user.id", int64(7))
+	child.End(errors.New("user_not_found"))
+	child.SetAttribute("ignored", true)
+	server.End(nil)
+	server.End(nil)
+
+	assert.Len(t, exporter.spans, 2)
+	assert.Equal(t, remote.TraceID, exporter.spans[0].Context.TraceID)
+	assert.Equal(t, server.Context().SpanID, exporter.spans[0].Parent)
+	assert.Equal(t, map[string]interface{}{"user.id": int64(7)}, exporter.spans[0].Attributes)
+	assert.Equal(t, "user_not_found", exporter.spans[0].Error)
+	assert.Equal(t, remote.SpanID, exporter.spans[1].Parent)
+
+	_, root := NewTracer(exporter, 0).Start(context.Background(), "unsampled", KindInternal)
+	root.End(nil)
+	assert.Len(t, exporter.spans, 2)
+	assert.True(t, root.Context().Valid(), "unsampled spans still propagate")
+	var nilSpan *Span
+	nilSpan.End(nil)
+}
+
+func TestStdoutExporter(t *testing.T) {
+	out := bytes.Buffer{}
+	tracer := NewTracer(NewStdoutExporter(&out, "api_base"), 1)
+
+	_, span := tracer.Start(context.Background(), "restclient.DoGet", KindClient)
+	span.SetAttribute("http.status_code", 200)
+	span.End(nil)
+
+	line := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "restclient.DoGet", line["name"])
+	assert.Equal(t, "client", line["kind"])
+	assert.Equal(t, span.Context().TraceID.String(), line["trace_id"])
+	assert.Nil(t, line["parent_id"])
+}
+
+func TestOTLPExporter(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer collector.Close()
+	exporter := NewOTLPExporter(Config{Endpoint: collector.URL + "/", Headers: map[string]string{"X-Api-Key": "secret"}, FlushIntervalSeconds: 60})
+	tracer := NewTracer(exporter, 1)
+
+	_, span := tracer.Start(context.Background(), "user.Repository.Get", KindInternal)
+	span.SetAttribute("db.system", "mysql")
+	span.End(errors.New("database_unavailable"))
+	assert.Nil(t, tracer.Shutdown(context.Background()))
+
+	request := otlpExportRequest{}
+	assert.Nil(t, json.Unmarshal(<-bodies, &request))
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "user.Repository.Get", spans[0].Name)
+	assert.Equal(t, 1, spans[0].Kind)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "database_unavailable"}, spans[0].Status)
+	assert.Equal(t, "db.system", spans[0].Attributes[0].Key)
+	assert.Equal(t, "service.name", request.ResourceSpans[0].Resource.Attributes[0].Key)
+}
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problems aggregates configuration problems as "path: message" entries so every
+// problem is reported at once instead of failing on the first one.
+type Problems []string
+
+// Addf records a problem on the field at path.
+func (p *Problems) Addf(path, format string, args ...interface{}) {
+	*p = append(*p, path+": "+fmt.Sprintf(format, args...))
+}
+
+// Merge records the problems of a nested config under prefix.
+func (p *Problems) Merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	nested, ok := err.(Problems)
+	if !ok {
+		p.Addf(prefix, "%v", err)
+		return
+	}
+	for _, problem := range nested {
+		*p = append(*p, prefix+"."+problem)
+	}
+}
+
+// Err returns the problems as an error, nil when there is none.
+func (p Problems) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+func (p Problems) Error() string {
+	return fmt.Sprintf("%d config problem(s): %s", len(p), strings.Join(p, "; "))
+}
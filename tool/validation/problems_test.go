@@ -0,0 +1,21 @@
+package validation
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProblems(t *testing.T) {
+	nested := Problems{}
+	assert.Nil(t, nested.Err())
+	nested.Addf("host", "is required")
+
+	problems := Problems{}
+	problems.Merge("database", nested.Err())
+	problems.Merge("server", nil)
+	problems.Merge("jwt", errors.New("boom"))
+
+	assert.Equal(t, Problems{"database.host: is required", "jwt: boom"}, problems.Err())
+	assert.EqualError(t, problems, "2 config problem(s): database.host: is required; jwt: boom")
+}